@@ -4,11 +4,13 @@ import (
 	"crypto/md5"
 	"encoding/hex"
 	"errors"
+	"fmt"
 	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -16,6 +18,7 @@ import (
 var (
 	ErrNoSuchBucket   = errors.New("no such bucket")
 	ErrBucketNotEmpty = errors.New("bucket not empty")
+	ErrNoSuchUpload   = errors.New("no such upload")
 )
 
 type LocalFS struct {
@@ -239,6 +242,10 @@ func (f *LocalFS) ListObjectsV2(bucket, prefix, delimiter, startAfter, continuat
 			return err
 		}
 		if d.IsDir() {
+			rel, _ := filepath.Rel(root, p)
+			if rel == ".uploads" {
+				return fs.SkipDir
+			}
 			return nil
 		}
 		rel, _ := filepath.Rel(root, p)
@@ -309,6 +316,260 @@ func (f *LocalFS) ListObjectsV2(bucket, prefix, delimiter, startAfter, continuat
 // byteRange is duplicated here for build independence from package main
 // Kept minimal: only fields used by FS.GetObject
 
+//
+//
+//
+
+// CompletedPart identifies a part by number and the ETag returned when it
+// was uploaded, as supplied to CompleteMultipartUpload.
+type CompletedPart struct {
+	PartNumber int
+	ETag       string
+}
+
+// PartInfo describes a part already staged against an in-progress
+// multipart upload, as returned by ListParts.
+type PartInfo struct {
+	PartNumber   int
+	ETag         string
+	Size         int64
+	LastModified time.Time
+}
+
+func (f *LocalFS) uploadDir(bucket, uploadID string) string {
+	return filepath.Join(f.bucketPath(bucket), ".uploads", uploadID)
+}
+
+func (f *LocalFS) CreateMultipartUpload(bucket, key string) (string, error) {
+	if err := f.MakeBucket(bucket); err != nil {
+		return "", err
+	}
+	uploadID, err := randomHex(16)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(f.uploadDir(bucket, uploadID), 0o755); err != nil {
+		return "", err
+	}
+	return uploadID, nil
+}
+
+func (f *LocalFS) UploadPart(bucket, key, uploadID string, partNumber int, body io.Reader, n int64) (string, error) {
+	dir := f.uploadDir(bucket, uploadID)
+	if _, err := os.Stat(dir); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", ErrNoSuchUpload
+		}
+		return "", err
+	}
+
+	p := filepath.Join(dir, strconv.Itoa(partNumber))
+	tmp := p + ".tmp"
+	wf, err := os.Create(tmp)
+	if err != nil {
+		return "", err
+	}
+	h := md5.New()
+	_, err = io.Copy(io.MultiWriter(wf, h), body)
+	cerr := wf.Close()
+	if err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return "", err
+	}
+	if err := os.Rename(tmp, p); err != nil {
+		_ = os.Remove(tmp)
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (f *LocalFS) CompleteMultipartUpload(bucket, key, uploadID string, parts []CompletedPart) (string, error) {
+	dir := f.uploadDir(bucket, uploadID)
+	if _, err := os.Stat(dir); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", ErrNoSuchUpload
+		}
+		return "", err
+	}
+
+	p := f.objPath(bucket, key)
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return "", err
+	}
+	tmp := p + ".tmp"
+	wf, err := os.Create(tmp)
+	if err != nil {
+		return "", err
+	}
+
+	var sums []byte
+	for _, part := range parts {
+		partPath := filepath.Join(dir, strconv.Itoa(part.PartNumber))
+		pf, err := os.Open(partPath)
+		if err != nil {
+			wf.Close()
+			_ = os.Remove(tmp)
+			return "", fmt.Errorf("part %d: %w", part.PartNumber, err)
+		}
+		h := md5.New()
+		_, err = io.Copy(io.MultiWriter(wf, h), pf)
+		pf.Close()
+		if err != nil {
+			wf.Close()
+			_ = os.Remove(tmp)
+			return "", err
+		}
+		partSum := h.Sum(nil)
+		if sum := hex.EncodeToString(partSum); part.ETag != "" && sum != part.ETag {
+			wf.Close()
+			_ = os.Remove(tmp)
+			return "", fmt.Errorf("part %d: etag mismatch", part.PartNumber)
+		}
+		sums = append(sums, partSum...)
+	}
+	if err := wf.Close(); err != nil {
+		_ = os.Remove(tmp)
+		return "", err
+	}
+	if err := os.Rename(tmp, p); err != nil {
+		_ = os.Remove(tmp)
+		return "", err
+	}
+
+	sum := md5.Sum(sums)
+	etag := fmt.Sprintf("%s-%d", hex.EncodeToString(sum[:]), len(parts))
+
+	_ = os.RemoveAll(dir)
+	return etag, nil
+}
+
+func (f *LocalFS) AbortMultipartUpload(bucket, key, uploadID string) error {
+	return os.RemoveAll(f.uploadDir(bucket, uploadID))
+}
+
+func (f *LocalFS) ListParts(bucket, key, uploadID string) ([]PartInfo, error) {
+	dir := f.uploadDir(bucket, uploadID)
+	dents, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrNoSuchUpload
+		}
+		return nil, err
+	}
+	var out []PartInfo
+	for _, de := range dents {
+		if de.IsDir() || strings.HasSuffix(de.Name(), ".tmp") {
+			continue
+		}
+		n, err := strconv.Atoi(de.Name())
+		if err != nil {
+			continue
+		}
+		etag, size, err := md5HexOfFile(filepath.Join(dir, de.Name()))
+		if err != nil {
+			continue
+		}
+		info, err := de.Info()
+		if err != nil {
+			continue
+		}
+		out = append(out, PartInfo{PartNumber: n, ETag: etag, Size: size, LastModified: info.ModTime()})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].PartNumber < out[j].PartNumber })
+	return out, nil
+}
+
+//
+//
+//
+
+// DeletedKey reports a key successfully removed by DeleteObjects.
+type DeletedKey struct {
+	Key string
+}
+
+// DeleteError reports a key DeleteObjects failed to remove.
+type DeleteError struct {
+	Key     string
+	Code    string
+	Message string
+}
+
+func (f *LocalFS) DeleteObjects(bucket string, keys []string) ([]DeletedKey, []DeleteError, error) {
+	if err := f.ensureBucket(bucket); err != nil {
+		return nil, nil, err
+	}
+	var deleted []DeletedKey
+	var fails []DeleteError
+	for _, key := range keys {
+		p := f.objPath(bucket, key)
+		if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+			fails = append(fails, DeleteError{Key: key, Code: "InternalError", Message: err.Error()})
+			continue
+		}
+		deleted = append(deleted, DeletedKey{Key: key})
+	}
+	return deleted, fails, nil
+}
+
+func (f *LocalFS) CopyObject(srcBucket, srcKey, dstBucket, dstKey string) (string, time.Time, error) {
+	if err := f.ensureBucket(srcBucket); err != nil {
+		return "", time.Time{}, err
+	}
+	if err := f.MakeBucket(dstBucket); err != nil {
+		return "", time.Time{}, err
+	}
+
+	srcPath := f.objPath(srcBucket, srcKey)
+	dstPath := f.objPath(dstBucket, dstKey)
+	if err := os.MkdirAll(filepath.Dir(dstPath), 0o755); err != nil {
+		return "", time.Time{}, err
+	}
+
+	tmp := dstPath + ".tmp"
+	_ = os.Remove(tmp)
+	if err := os.Link(srcPath, tmp); err != nil {
+		// Cross-device or other link failure: fall back to a streamed copy.
+		if err := copyFile(srcPath, tmp); err != nil {
+			return "", time.Time{}, err
+		}
+	}
+	if err := os.Rename(tmp, dstPath); err != nil {
+		_ = os.Remove(tmp)
+		return "", time.Time{}, err
+	}
+
+	etag, _, err := md5HexOfFile(dstPath)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	st, err := os.Stat(dstPath)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return etag, st.ModTime(), nil
+}
+
+func copyFile(src, dst string) error {
+	sf, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer sf.Close()
+	df, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(df, sf); err != nil {
+		df.Close()
+		return err
+	}
+	return df.Close()
+}
+
 func md5HexOfFile(p string) (string, int64, error) {
 	f, err := os.Open(p)
 	if err != nil {
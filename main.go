@@ -17,8 +17,13 @@ import (
 func main() {
 	fs := ff.NewFlagSet("tinys3")
 	var (
-		flagAddr = fs.String('a', "addr", ":1234", "S3-compatible endpoint listen address")
-		flagRoot = fs.String('r', "root", "/tmp/tinys3", "root directory for storage")
+		flagAddr       = fs.String('a', "addr", ":1234", "S3-compatible endpoint listen address")
+		flagRoot       = fs.String('r', "root", "/tmp/tinys3", "root directory for storage (localfs backend)")
+		flagBackend    = fs.String('b', "backend", "localfs", "storage backend: localfs, memfs, or s3proxy")
+		flagUpstream   = fs.String(0, "upstream", "", "upstream S3 endpoint URL (s3proxy backend)")
+		flagAccessKey  = fs.String(0, "access-key", "", "AWS access key ID to require (leave empty to disable auth)")
+		flagSecretKey  = fs.String(0, "secret-key", "", "AWS secret access key paired with --access-key")
+		flagWebDAVAddr = fs.String(0, "webdav-addr", "", "WebDAV listen address (leave empty to disable)")
 	)
 
 	err := ff.Parse(fs, os.Args[1:])
@@ -33,12 +38,25 @@ func main() {
 
 	log.SetFlags(log.LUTC | log.Ltime | log.Lmicroseconds)
 
-	if err := os.MkdirAll(*flagRoot, 0o755); err != nil {
-		log.Fatalf("mkdir root: %v", err)
+	if *flagBackend == "localfs" {
+		if err := os.MkdirAll(*flagRoot, 0o755); err != nil {
+			log.Fatalf("mkdir root: %v", err)
+		}
 	}
 
-	objectStore := NewLocalFS(*flagRoot)
-	mux := NewRouter(objectStore)
+	var creds Credentials
+	if *flagAccessKey != "" {
+		creds = StaticCredentials{{AccessKeyID: *flagAccessKey, SecretAccessKey: *flagSecretKey}}
+	}
+
+	objectStore, err := newBackend(*flagBackend, map[string]string{
+		"root":     *flagRoot,
+		"endpoint": *flagUpstream,
+	})
+	if err != nil {
+		log.Fatalf("backend %q: %v", *flagBackend, err)
+	}
+	mux := NewRouter(objectStore, creds)
 
 	ctx := context.Background()
 
@@ -54,7 +72,27 @@ func main() {
 	}
 
 	log.Printf("tinys3 serving %s on %s", *flagRoot, *flagAddr)
+	if creds != nil {
+		log.Printf("SigV4 authentication required (access key %s)", *flagAccessKey)
+	}
 	log.Printf("usage: aws --endpoint-url=URL s3 ls s3://")
 
+	if *flagWebDAVAddr != "" {
+		webdavLn, err := unixtransport.ListenURI(ctx, *flagWebDAVAddr)
+		if err != nil {
+			log.Fatalf("webdav listen: %v", err)
+		}
+		defer webdavLn.Close()
+
+		webdavServer := &http.Server{
+			Handler:           NewWebDAVHandler(objectStore, creds),
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		go func() {
+			log.Printf("tinys3 serving WebDAV on %s", *flagWebDAVAddr)
+			log.Fatal(webdavServer.Serve(webdavLn))
+		}()
+	}
+
 	log.Fatal(server.Serve(ln))
 }
@@ -0,0 +1,384 @@
+package main
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type memObject struct {
+	data []byte
+	etag string
+	mod  time.Time
+}
+
+type memUpload struct {
+	key   string
+	parts map[int][]byte
+}
+
+// MemFS is a map-based, in-memory ObjectStore. It's useful for tests and
+// ephemeral CI containers that shouldn't touch disk, similar in spirit to
+// fake-gcs-server's in-memory mode.
+type MemFS struct {
+	mu      sync.Mutex
+	buckets map[string]time.Time
+	objects map[string]map[string]*memObject
+	uploads map[string]map[string]*memUpload
+}
+
+func NewMemFS() *MemFS {
+	return &MemFS{
+		buckets: map[string]time.Time{},
+		objects: map[string]map[string]*memObject{},
+		uploads: map[string]map[string]*memUpload{},
+	}
+}
+
+//
+//
+//
+
+func (m *MemFS) ListBuckets() ([]BucketInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []BucketInfo
+	for name, created := range m.buckets {
+		out = append(out, BucketInfo{Name: name, CreationDate: created})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+func (m *MemFS) MakeBucket(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.makeBucketLocked(name)
+}
+
+func (m *MemFS) makeBucketLocked(name string) error {
+	if _, ok := m.buckets[name]; ok {
+		return nil
+	}
+	m.buckets[name] = time.Now()
+	m.objects[name] = map[string]*memObject{}
+	return nil
+}
+
+func (m *MemFS) DeleteBucket(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.buckets[name]; !ok {
+		return ErrNoSuchBucket
+	}
+	if len(m.objects[name]) > 0 {
+		return ErrBucketNotEmpty
+	}
+	delete(m.buckets, name)
+	delete(m.objects, name)
+	delete(m.uploads, name)
+	return nil
+}
+
+func (m *MemFS) getLocked(bucket, key string) (*memObject, error) {
+	objs, ok := m.objects[bucket]
+	if !ok {
+		return nil, ErrNoSuchBucket
+	}
+	obj, ok := objs[key]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return obj, nil
+}
+
+//
+//
+//
+
+func (m *MemFS) PutObject(bucket, key string, body io.Reader, n int64) (string, int64, time.Time, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return "", 0, time.Time{}, err
+	}
+	sum := md5.Sum(data)
+	etag := hex.EncodeToString(sum[:])
+	mod := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.makeBucketLocked(bucket); err != nil {
+		return "", 0, time.Time{}, err
+	}
+	m.objects[bucket][key] = &memObject{data: data, etag: etag, mod: mod}
+	return etag, int64(len(data)), mod, nil
+}
+
+func (m *MemFS) GetObject(bucket, key string, br *ByteRange) (io.ReadCloser, int64, string, time.Time, error) {
+	m.mu.Lock()
+	obj, err := m.getLocked(bucket, key)
+	m.mu.Unlock()
+	if err != nil {
+		return nil, 0, "", time.Time{}, err
+	}
+
+	size := int64(len(obj.data))
+	if br == nil {
+		return io.NopCloser(bytes.NewReader(obj.data)), size, obj.etag, obj.mod, nil
+	}
+
+	var start, length int64
+	switch {
+	case br.Start == -1: // suffix
+		length = br.Length
+		if length > size {
+			length = size
+		}
+		start = size - length
+	case br.End == -1: // open-ended
+		start = br.Start
+		length = size - start
+	default:
+		start = br.Start
+		length = br.Length
+	}
+	if start < 0 || start >= size {
+		return nil, 0, "", time.Time{}, os.ErrNotExist
+	}
+	return io.NopCloser(bytes.NewReader(obj.data[start : start+length])), length, obj.etag, obj.mod, nil
+}
+
+func (m *MemFS) HeadObject(bucket, key string) (int64, string, time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	obj, err := m.getLocked(bucket, key)
+	if err != nil {
+		return 0, "", time.Time{}, err
+	}
+	return int64(len(obj.data)), obj.etag, obj.mod, nil
+}
+
+func (m *MemFS) DeleteObject(bucket, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	objs, ok := m.objects[bucket]
+	if !ok {
+		return ErrNoSuchBucket
+	}
+	delete(objs, key)
+	return nil
+}
+
+func (m *MemFS) ListObjectsV2(bucket, prefix, delimiter, startAfter, continuationToken string, maxKeys int) (ListV2, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	objs, ok := m.objects[bucket]
+	if !ok {
+		return ListV2{}, ErrNoSuchBucket
+	}
+
+	var all []ObjInfo
+	for key, obj := range objs {
+		if prefix != "" && !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		all = append(all, ObjInfo{Key: key, Size: int64(len(obj.data)), ETag: obj.etag, LastModified: obj.mod})
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Key < all[j].Key })
+
+	start := 0
+	if continuationToken != "" {
+		for i := range all {
+			if all[i].Key > continuationToken {
+				start = i
+				break
+			}
+		}
+	}
+	if startAfter != "" {
+		for i := start; i < len(all); i++ {
+			if all[i].Key > startAfter {
+				start = i
+				break
+			}
+		}
+	}
+	end := min(start+maxKeys, len(all))
+	page := all[start:end]
+
+	res := ListV2{}
+	if delimiter == "" {
+		res.Contents = append(res.Contents, page...)
+	} else {
+		seen := map[string]struct{}{}
+		for _, oi := range page {
+			rest := strings.TrimPrefix(oi.Key, prefix)
+			if i := strings.Index(rest, delimiter); i >= 0 {
+				pref := prefix + rest[:i+1]
+				if _, ok := seen[pref]; !ok {
+					res.CommonPrefixes = append(res.CommonPrefixes, pref)
+					seen[pref] = struct{}{}
+				}
+				continue
+			}
+			res.Contents = append(res.Contents, oi)
+		}
+	}
+	if end < len(all) {
+		res.IsTruncated = true
+		res.NextContinuationToken = all[end-1].Key
+	}
+	return res, nil
+}
+
+//
+//
+//
+
+func (m *MemFS) CreateMultipartUpload(bucket, key string) (string, error) {
+	uploadID, err := randomHex(16)
+	if err != nil {
+		return "", err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.makeBucketLocked(bucket); err != nil {
+		return "", err
+	}
+	if m.uploads[bucket] == nil {
+		m.uploads[bucket] = map[string]*memUpload{}
+	}
+	m.uploads[bucket][uploadID] = &memUpload{key: key, parts: map[int][]byte{}}
+	return uploadID, nil
+}
+
+func (m *MemFS) uploadLocked(bucket, uploadID string) (*memUpload, error) {
+	ups, ok := m.uploads[bucket]
+	if !ok {
+		return nil, ErrNoSuchUpload
+	}
+	up, ok := ups[uploadID]
+	if !ok {
+		return nil, ErrNoSuchUpload
+	}
+	return up, nil
+}
+
+func (m *MemFS) UploadPart(bucket, key, uploadID string, partNumber int, body io.Reader, n int64) (string, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return "", err
+	}
+	sum := md5.Sum(data)
+	etag := hex.EncodeToString(sum[:])
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	up, err := m.uploadLocked(bucket, uploadID)
+	if err != nil {
+		return "", err
+	}
+	up.parts[partNumber] = data
+	return etag, nil
+}
+
+func (m *MemFS) CompleteMultipartUpload(bucket, key, uploadID string, parts []CompletedPart) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	up, err := m.uploadLocked(bucket, uploadID)
+	if err != nil {
+		return "", err
+	}
+
+	var body []byte
+	var sums []byte
+	for _, part := range parts {
+		data, ok := up.parts[part.PartNumber]
+		if !ok {
+			return "", fmt.Errorf("part %d: not found", part.PartNumber)
+		}
+		sum := md5.Sum(data)
+		if part.ETag != "" && hex.EncodeToString(sum[:]) != part.ETag {
+			return "", fmt.Errorf("part %d: etag mismatch", part.PartNumber)
+		}
+		body = append(body, data...)
+		sums = append(sums, sum[:]...)
+	}
+
+	finalSum := md5.Sum(sums)
+	etag := fmt.Sprintf("%s-%d", hex.EncodeToString(finalSum[:]), len(parts))
+
+	if err := m.makeBucketLocked(bucket); err != nil {
+		return "", err
+	}
+	m.objects[bucket][key] = &memObject{data: body, etag: etag, mod: time.Now()}
+	delete(m.uploads[bucket], uploadID)
+	return etag, nil
+}
+
+func (m *MemFS) AbortMultipartUpload(bucket, key, uploadID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if ups, ok := m.uploads[bucket]; ok {
+		delete(ups, uploadID)
+	}
+	return nil
+}
+
+func (m *MemFS) ListParts(bucket, key, uploadID string) ([]PartInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	up, err := m.uploadLocked(bucket, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	var out []PartInfo
+	for n, data := range up.parts {
+		sum := md5.Sum(data)
+		out = append(out, PartInfo{PartNumber: n, ETag: hex.EncodeToString(sum[:]), Size: int64(len(data))})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].PartNumber < out[j].PartNumber })
+	return out, nil
+}
+
+//
+//
+//
+
+func (m *MemFS) CopyObject(srcBucket, srcKey, dstBucket, dstKey string) (string, time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	src, err := m.getLocked(srcBucket, srcKey)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if err := m.makeBucketLocked(dstBucket); err != nil {
+		return "", time.Time{}, err
+	}
+	data := append([]byte(nil), src.data...)
+	mod := time.Now()
+	m.objects[dstBucket][dstKey] = &memObject{data: data, etag: src.etag, mod: mod}
+	return src.etag, mod, nil
+}
+
+func (m *MemFS) DeleteObjects(bucket string, keys []string) ([]DeletedKey, []DeleteError, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	objs, ok := m.objects[bucket]
+	if !ok {
+		return nil, nil, ErrNoSuchBucket
+	}
+	var deleted []DeletedKey
+	for _, key := range keys {
+		delete(objs, key)
+		deleted = append(deleted, DeletedKey{Key: key})
+	}
+	return deleted, nil, nil
+}
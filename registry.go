@@ -0,0 +1,48 @@
+package main
+
+import "fmt"
+
+// BackendFactory constructs an ObjectStore from a flat string config, as
+// selected by the --backend flag.
+type BackendFactory func(cfg map[string]string) (ObjectStore, error)
+
+var backends = map[string]BackendFactory{}
+
+// RegisterBackend makes an ObjectStore implementation selectable via
+// --backend without requiring callers to fork tinys3. Third parties can
+// call this from an init() in their own package (GCS, B2, Azure, ...) as
+// long as it's imported somewhere in main.
+func RegisterBackend(name string, factory BackendFactory) {
+	backends[name] = factory
+}
+
+// newBackend constructs the ObjectStore registered under name.
+func newBackend(name string, cfg map[string]string) (ObjectStore, error) {
+	factory, ok := backends[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown backend %q", name)
+	}
+	return factory(cfg)
+}
+
+func init() {
+	RegisterBackend("localfs", func(cfg map[string]string) (ObjectStore, error) {
+		root := cfg["root"]
+		if root == "" {
+			root = "/tmp/tinys3"
+		}
+		return NewLocalFS(root), nil
+	})
+
+	RegisterBackend("memfs", func(cfg map[string]string) (ObjectStore, error) {
+		return NewMemFS(), nil
+	})
+
+	RegisterBackend("s3proxy", func(cfg map[string]string) (ObjectStore, error) {
+		endpoint := cfg["endpoint"]
+		if endpoint == "" {
+			return nil, fmt.Errorf("s3proxy backend requires --upstream")
+		}
+		return NewS3Proxy(endpoint), nil
+	})
+}
@@ -7,10 +7,14 @@ import (
 	"io"
 	"log"
 	"mime"
+	"mime/multipart"
 	"net/http"
+	"net/textproto"
+	"net/url"
 	"os"
 	"path"
 	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -25,13 +29,25 @@ type ObjectStore interface {
 	GetObject(bucket, key string, rng *ByteRange) (rc io.ReadCloser, size int64, etag string, mod time.Time, err error)
 	HeadObject(bucket, key string) (size int64, etag string, mod time.Time, err error)
 	DeleteObject(bucket, key string) error
+
+	CreateMultipartUpload(bucket, key string) (uploadID string, err error)
+	UploadPart(bucket, key, uploadID string, partNumber int, body io.Reader, n int64) (etag string, err error)
+	CompleteMultipartUpload(bucket, key, uploadID string, parts []CompletedPart) (etag string, err error)
+	AbortMultipartUpload(bucket, key, uploadID string) error
+	ListParts(bucket, key, uploadID string) ([]PartInfo, error)
+
+	CopyObject(srcBucket, srcKey, dstBucket, dstKey string) (etag string, mod time.Time, err error)
+	DeleteObjects(bucket string, keys []string) ([]DeletedKey, []DeleteError, error)
 }
 
 //
 //
 //
 
-func NewRouter(objectStore ObjectStore) http.Handler {
+// NewRouter builds the S3-compatible handler for objectStore. If creds is
+// non-nil, every request must carry a valid AWS SigV4 signature (header or
+// presigned query params); pass nil to leave the endpoint unauthenticated.
+func NewRouter(objectStore ObjectStore, creds Credentials) http.Handler {
 	mux := http.NewServeMux()
 
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
@@ -59,16 +75,11 @@ func NewRouter(objectStore ObjectStore) http.Handler {
 		}
 
 		// Path-style bucket or object
-		parts := strings.Split(strings.TrimPrefix(path.Clean(r.URL.Path), "/"), "/")
-		if len(parts) == 0 || parts[0] == "" {
+		bucket, key := splitBucketKey(r.URL.Path)
+		if bucket == "" {
 			writeS3Error(w, http.StatusNotFound, "NoSuchBucket", "")
 			return
 		}
-		bucket := parts[0]
-		key := ""
-		if len(parts) > 1 {
-			key = strings.Join(parts[1:], "/")
-		}
 
 		if key == "" {
 			// Bucket-level ops
@@ -131,6 +142,35 @@ func NewRouter(objectStore ObjectStore) http.Handler {
 				writeXML(w, http.StatusOK, resp)
 				log.Printf("LIST %s prefix=%q delimiter=%q startAfter=%q continuationToken=%q maxKeys=%d", bucket, prefix, delimiter, startAfter, cont, maxKeys)
 				return
+			case http.MethodPost:
+				if _, ok := r.URL.Query()["delete"]; !ok {
+					writeS3Error(w, http.StatusNotImplemented, "NotImplemented", "")
+					return
+				}
+				var req DeleteRequest
+				if err := xml.NewDecoder(r.Body).Decode(&req); err != nil {
+					writeS3Error(w, http.StatusBadRequest, "MalformedXML", err.Error())
+					return
+				}
+				keys := make([]string, len(req.Object))
+				for i, o := range req.Object {
+					keys[i] = o.Key
+				}
+				deleted, delErrs, err := objectStore.DeleteObjects(bucket, keys)
+				if err != nil {
+					writeFsErr(w, err)
+					return
+				}
+				resp := DeleteResult{Xmlns: s3ns}
+				for _, d := range deleted {
+					resp.Deleted = append(resp.Deleted, DeletedObject{Key: d.Key})
+				}
+				for _, e := range delErrs {
+					resp.Error = append(resp.Error, DeleteErrorXML{Key: e.Key, Code: e.Code, Message: e.Message})
+				}
+				log.Printf("DELETE BATCH %s count=%d errors=%d", bucket, len(deleted), len(delErrs))
+				writeXML(w, http.StatusOK, resp)
+				return
 			default:
 				writeS3Error(w, http.StatusMethodNotAllowed, "MethodNotAllowed", "")
 				return
@@ -138,8 +178,144 @@ func NewRouter(objectStore ObjectStore) http.Handler {
 		}
 
 		// Object-level ops
+
+		// Multipart upload endpoints are distinguished by query string rather
+		// than method, so they're dispatched ahead of the regular verb switch.
+		q := r.URL.Query()
+		if _, ok := q["uploads"]; ok && r.Method == http.MethodPost {
+			uploadID, err := objectStore.CreateMultipartUpload(bucket, key)
+			if err != nil {
+				writeFsErr(w, err)
+				return
+			}
+			log.Printf("MULTIPART CREATE %s/%s uploadId=%s", bucket, key, uploadID)
+			writeXML(w, http.StatusOK, InitiateMultipartUploadResult{
+				Xmlns:    s3ns,
+				Bucket:   bucket,
+				Key:      key,
+				UploadID: uploadID,
+			})
+			return
+		}
+		if uploadID := q.Get("uploadId"); uploadID != "" {
+			// uploadID ends up in filepath.Join calls in the localfs backend, so
+			// it's validated against the exact shape randomHex(16) produces
+			// before it ever reaches an ObjectStore method; otherwise a crafted
+			// uploadId (e.g. containing "../") could escape the bucket directory.
+			if !uploadIDPattern.MatchString(uploadID) {
+				writeS3Error(w, http.StatusNotFound, "NoSuchUpload", "")
+				return
+			}
+			switch r.Method {
+			case http.MethodPut:
+				partNumber := atoiDefault(q.Get("partNumber"), 0)
+				if partNumber < 1 {
+					writeS3Error(w, http.StatusBadRequest, "InvalidArgument", "partNumber")
+					return
+				}
+				etag, err := objectStore.UploadPart(bucket, key, uploadID, partNumber, r.Body, r.ContentLength)
+				if err != nil {
+					writeFsErr(w, err)
+					return
+				}
+				log.Printf("MULTIPART PUT PART %s/%s uploadId=%s part=%d etag=%s", bucket, key, uploadID, partNumber, etag)
+				w.Header().Set("ETag", fmt.Sprintf("\"%s\"", etag))
+				w.WriteHeader(http.StatusOK)
+				return
+
+			case http.MethodPost:
+				var req CompleteMultipartUploadRequest
+				if err := xml.NewDecoder(r.Body).Decode(&req); err != nil {
+					writeS3Error(w, http.StatusBadRequest, "MalformedXML", err.Error())
+					return
+				}
+				parts := make([]CompletedPart, 0, len(req.Part))
+				for _, p := range req.Part {
+					parts = append(parts, CompletedPart{PartNumber: p.PartNumber, ETag: strings.Trim(p.ETag, `"`)})
+				}
+				etag, err := objectStore.CompleteMultipartUpload(bucket, key, uploadID, parts)
+				if err != nil {
+					writeFsErr(w, err)
+					return
+				}
+				log.Printf("MULTIPART COMPLETE %s/%s uploadId=%s etag=%s", bucket, key, uploadID, etag)
+				writeXML(w, http.StatusOK, CompleteMultipartUploadResult{
+					Xmlns:  s3ns,
+					Bucket: bucket,
+					Key:    key,
+					ETag:   fmt.Sprintf("\"%s\"", etag),
+				})
+				return
+
+			case http.MethodDelete:
+				if err := objectStore.AbortMultipartUpload(bucket, key, uploadID); err != nil {
+					writeFsErr(w, err)
+					return
+				}
+				log.Printf("MULTIPART ABORT %s/%s uploadId=%s", bucket, key, uploadID)
+				w.WriteHeader(http.StatusNoContent)
+				return
+
+			case http.MethodGet:
+				parts, err := objectStore.ListParts(bucket, key, uploadID)
+				if err != nil {
+					writeFsErr(w, err)
+					return
+				}
+				resp := ListPartsResult{Xmlns: s3ns, Bucket: bucket, Key: key, UploadID: uploadID}
+				for _, p := range parts {
+					resp.Part = append(resp.Part, Part{
+						PartNumber:   p.PartNumber,
+						ETag:         fmt.Sprintf("\"%s\"", p.ETag),
+						Size:         p.Size,
+						LastModified: p.LastModified.UTC().Format(time.RFC3339),
+					})
+				}
+				log.Printf("MULTIPART LIST PARTS %s/%s uploadId=%s count=%d", bucket, key, uploadID, len(parts))
+				writeXML(w, http.StatusOK, resp)
+				return
+
+			default:
+				writeS3Error(w, http.StatusMethodNotAllowed, "MethodNotAllowed", "")
+				return
+			}
+		}
+
 		switch r.Method {
 		case http.MethodPut:
+			if hasConditionalHeaders(r) {
+				_, etag, mod, err := objectStore.HeadObject(bucket, key)
+				exists := err == nil
+				if err != nil && !errors.Is(err, os.ErrNotExist) {
+					writeFsErr(w, err)
+					return
+				}
+				if status, ok := evaluateConditional(r, exists, etag, mod); !ok {
+					w.WriteHeader(status)
+					return
+				}
+			}
+
+			if copySource := r.Header.Get("x-amz-copy-source"); copySource != "" {
+				unescaped, err := url.QueryUnescape(copySource)
+				if err != nil {
+					unescaped = copySource
+				}
+				srcBucket, srcKey := splitBucketKey(unescaped)
+				etag, mod, err := objectStore.CopyObject(srcBucket, srcKey, bucket, key)
+				if err != nil {
+					writeFsErr(w, err)
+					return
+				}
+				log.Printf("COPY %s/%s -> %s/%s etag=%s", srcBucket, srcKey, bucket, key, etag)
+				writeXML(w, http.StatusOK, CopyObjectResult{
+					Xmlns:        s3ns,
+					ETag:         fmt.Sprintf("\"%s\"", etag),
+					LastModified: mod.UTC().Format(time.RFC3339),
+				})
+				return
+			}
+
 			// Auto-create bucket if missing
 			_ = objectStore.MakeBucket(bucket)
 
@@ -156,38 +332,87 @@ func NewRouter(objectStore ObjectStore) http.Handler {
 			return
 
 		case http.MethodGet:
-			br, _ := ParseRange(r.Header.Get("Range"))
-			rc, size, etag, mod, err := objectStore.GetObject(bucket, key, br)
+			ranges, rangeStatus := ParseRange(r.Header.Get("Range"))
+			if rangeStatus == http.StatusRequestedRangeNotSatisfiable {
+				writeS3Error(w, http.StatusRequestedRangeNotSatisfiable, "InvalidRange", "")
+				return
+			}
+
+			size, etag, mod, err := objectStore.HeadObject(bucket, key)
 			if err != nil {
 				writeFsErr(w, err)
 				return
 			}
-			defer rc.Close()
+			if status, ok := evaluateConditional(r, true, etag, mod); !ok {
+				w.WriteHeader(status)
+				return
+			}
 
 			ctype := mime.TypeByExtension(filepath.Ext(key))
 			if ctype == "" {
 				ctype = "application/octet-stream"
 			}
-
 			w.Header().Set("ETag", fmt.Sprintf("\"%s\"", etag))
 			w.Header().Set("Last-Modified", mod.UTC().Format(http.TimeFormat))
 			w.Header().Set("Accept-Ranges", "bytes")
-			w.Header().Set("Content-Type", ctype)
 
-			var written int64
-			if br != nil {
-				w.Header().Set("Content-Range", br.ContentRange(size))
-				w.WriteHeader(http.StatusPartialContent)
-				written, err = io.CopyN(w, rc, br.Length)
-			} else {
+			switch len(ranges) {
+			case 0:
+				rc, _, _, _, err := objectStore.GetObject(bucket, key, nil)
+				if err != nil {
+					writeFsErr(w, err)
+					return
+				}
+				defer rc.Close()
+				w.Header().Set("Content-Type", ctype)
 				w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
-				written, err = io.Copy(w, rc)
-			}
-			if err != nil && !errors.Is(err, io.EOF) {
-				log.Printf("GET copy error: %v", err)
+				if _, err := io.Copy(w, rc); err != nil && !errors.Is(err, io.EOF) {
+					log.Printf("GET copy error: %v", err)
+				}
+
+			case 1:
+				br := ranges[0]
+				contentRange := br.ContentRange(size)
+				rc, n, _, _, err := objectStore.GetObject(bucket, key, &br)
+				if err != nil {
+					writeFsErr(w, err)
+					return
+				}
+				defer rc.Close()
+				w.Header().Set("Content-Type", ctype)
+				w.Header().Set("Content-Range", contentRange)
+				w.Header().Set("Content-Length", strconv.FormatInt(n, 10))
+				w.WriteHeader(http.StatusPartialContent)
+				if _, err := io.CopyN(w, rc, n); err != nil && !errors.Is(err, io.EOF) {
+					log.Printf("GET copy error: %v", err)
+				}
+
+			default:
+				mw := multipart.NewWriter(w)
+				w.Header().Set("Content-Type", "multipart/byteranges; boundary="+mw.Boundary())
+				w.WriteHeader(http.StatusPartialContent)
+				for _, br := range ranges {
+					contentRange := br.ContentRange(size)
+					rc, n, _, _, err := objectStore.GetObject(bucket, key, &br)
+					if err != nil {
+						log.Printf("GET range copy error: %v", err)
+						break
+					}
+					part, err := mw.CreatePart(textproto.MIMEHeader{
+						"Content-Type":  {ctype},
+						"Content-Range": {contentRange},
+					})
+					if err == nil {
+						if _, err := io.CopyN(part, rc, n); err != nil && !errors.Is(err, io.EOF) {
+							log.Printf("GET range copy error: %v", err)
+						}
+					}
+					rc.Close()
+				}
+				mw.Close()
 			}
-			_ = written
-			log.Printf("GET %s/%s size=%d etag=%s written=%d", bucket, key, size, etag, written)
+
+			log.Printf("GET %s/%s size=%d etag=%s ranges=%d", bucket, key, size, etag, len(ranges))
 			return
 
 		case http.MethodHead:
@@ -196,6 +421,10 @@ func NewRouter(objectStore ObjectStore) http.Handler {
 				writeFsErr(w, err)
 				return
 			}
+			if status, ok := evaluateConditional(r, true, etag, mod); !ok {
+				w.WriteHeader(status)
+				return
+			}
 			w.Header().Set("ETag", fmt.Sprintf("\"%s\"", etag))
 			w.Header().Set("Last-Modified", mod.UTC().Format(http.TimeFormat))
 			w.Header().Set("Accept-Ranges", "bytes")
@@ -204,6 +433,18 @@ func NewRouter(objectStore ObjectStore) http.Handler {
 			return
 
 		case http.MethodDelete:
+			if hasConditionalHeaders(r) {
+				_, etag, mod, err := objectStore.HeadObject(bucket, key)
+				exists := err == nil
+				if err != nil && !errors.Is(err, os.ErrNotExist) {
+					writeFsErr(w, err)
+					return
+				}
+				if status, ok := evaluateConditional(r, exists, etag, mod); !ok {
+					w.WriteHeader(status)
+					return
+				}
+			}
 			if err := objectStore.DeleteObject(bucket, key); err != nil {
 				writeFsErr(w, err)
 				return
@@ -217,7 +458,21 @@ func NewRouter(objectStore ObjectStore) http.Handler {
 		}
 	})
 
-	return mux
+	return requireSigV4(creds, mux)
+}
+
+// splitBucketKey splits a path-style request path into its bucket and key
+// components. The key is empty for bucket-level (or root) requests.
+func splitBucketKey(urlPath string) (bucket, key string) {
+	parts := strings.Split(strings.TrimPrefix(path.Clean(urlPath), "/"), "/")
+	if len(parts) == 0 || parts[0] == "" {
+		return "", ""
+	}
+	bucket = parts[0]
+	if len(parts) > 1 {
+		key = strings.Join(parts[1:], "/")
+	}
+	return bucket, key
 }
 
 //
@@ -226,6 +481,10 @@ func NewRouter(objectStore ObjectStore) http.Handler {
 
 const s3ns = "http://s3.amazonaws.com/doc/2006-03-01/"
 
+// uploadIDPattern matches the hex upload IDs randomHex(16) generates in
+// CreateMultipartUpload.
+var uploadIDPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
 func atoiDefault(s string, def int) int {
 	if s == "" {
 		return def
@@ -237,6 +496,63 @@ func atoiDefault(s string, def int) int {
 	return n
 }
 
+// evaluateConditional checks the S3 conditional request headers
+// (If-Match/If-None-Match/If-Modified-Since/If-Unmodified-Since) against
+// the current state of an object, which may not exist. ok is false if the
+// caller should abort the request immediately with the returned status;
+// GET/HEAD abort with 304 Not Modified where PUT/DELETE abort with 412
+// Precondition Failed, per RFC 7232.
+func evaluateConditional(r *http.Request, exists bool, etag string, mod time.Time) (status int, ok bool) {
+	quoted := fmt.Sprintf("%q", etag)
+	notModifiedOrFailed := http.StatusPreconditionFailed
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		notModifiedOrFailed = http.StatusNotModified
+	}
+
+	if im := r.Header.Get("If-Match"); im != "" {
+		if !exists || !etagMatchesAny(im, quoted) {
+			return http.StatusPreconditionFailed, false
+		}
+	}
+	if ius := r.Header.Get("If-Unmodified-Since"); ius != "" {
+		if t, err := http.ParseTime(ius); err == nil && exists && mod.Truncate(time.Second).After(t) {
+			return http.StatusPreconditionFailed, false
+		}
+	}
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if exists && etagMatchesAny(inm, quoted) {
+			return notModifiedOrFailed, false
+		}
+	} else if ims := r.Header.Get("If-Modified-Since"); ims != "" {
+		if t, err := http.ParseTime(ims); err == nil && exists && !mod.Truncate(time.Second).After(t) {
+			if r.Method == http.MethodGet || r.Method == http.MethodHead {
+				return http.StatusNotModified, false
+			}
+		}
+	}
+	return 0, true
+}
+
+// hasConditionalHeaders reports whether r carries any of the conditional
+// request headers PUT/DELETE need to check before mutating an object.
+func hasConditionalHeaders(r *http.Request) bool {
+	return r.Header.Get("If-Match") != "" || r.Header.Get("If-None-Match") != "" ||
+		r.Header.Get("If-Modified-Since") != "" || r.Header.Get("If-Unmodified-Since") != ""
+}
+
+// etagMatchesAny reports whether header (a "*" or a comma-separated list of
+// quoted ETags, weak or strong) matches etag.
+func etagMatchesAny(header, etag string) bool {
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimPrefix(strings.TrimSpace(part), "W/")
+		if part == "*" || part == etag {
+			return true
+		}
+	}
+	return false
+}
+
 func writeFsErr(w http.ResponseWriter, err error) {
 	switch {
 	case errors.Is(err, os.ErrNotExist):
@@ -245,6 +561,12 @@ func writeFsErr(w http.ResponseWriter, err error) {
 		writeS3Error(w, http.StatusNotFound, "NoSuchBucket", err.Error())
 	case errors.Is(err, ErrBucketNotEmpty):
 		writeS3Error(w, http.StatusConflict, "BucketNotEmpty", err.Error())
+	case errors.Is(err, ErrNoSuchUpload):
+		writeS3Error(w, http.StatusNotFound, "NoSuchUpload", err.Error())
+	case errors.Is(err, errPayloadSHA256Mismatch):
+		writeS3Error(w, http.StatusBadRequest, "XAmzContentSHA256Mismatch", err.Error())
+	case errors.Is(err, errChunkSignatureMismatch):
+		writeS3Error(w, http.StatusForbidden, "SignatureDoesNotMatch", err.Error())
 	default:
 		writeS3Error(w, http.StatusInternalServerError, "InternalError", err.Error())
 	}
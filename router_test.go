@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTestRouter(t *testing.T) http.Handler {
+	t.Helper()
+	return NewRouter(NewLocalFS(t.TempDir()), nil)
+}
+
+// TestUploadIdRejectsPathTraversal is a regression test for a reported
+// vulnerability where an attacker-controlled uploadId (e.g. containing
+// "../") reached the localfs backend's filepath.Join unchecked, letting
+// multipart endpoints read or write outside the requesting bucket.
+func TestUploadIdRejectsPathTraversal(t *testing.T) {
+	mux := newTestRouter(t)
+
+	for _, uploadID := range []string{
+		"../../victim-bucket",
+		"../escape",
+		"not-hex-and-wrong-length",
+	} {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPut, "/attacker-bucket/k?uploadId="+uploadID+"&partNumber=1", nil)
+		mux.ServeHTTP(rec, req)
+		if rec.Code == http.StatusOK {
+			t.Fatalf("UploadPart with uploadId=%q: status = %d, want rejection", uploadID, rec.Code)
+		}
+	}
+}
+
+func TestMultipartUploadRoundTrip(t *testing.T) {
+	mux := newTestRouter(t)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/bucket/obj?uploads", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("CreateMultipartUpload: status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+	var result InitiateMultipartUploadResult
+	if err := xml.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatalf("decode InitiateMultipartUploadResult: %v", err)
+	}
+	if !uploadIDPattern.MatchString(result.UploadID) {
+		t.Fatalf("server-issued uploadId %q does not match the expected shape", result.UploadID)
+	}
+
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPut, "/bucket/obj?uploadId="+result.UploadID+"&partNumber=1", nil)
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("UploadPart: status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+}
+
+func putTestObject(t *testing.T, mux http.Handler, path, body string) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, path, strings.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("PUT %s: status = %d, body = %s", path, rec.Code, rec.Body.String())
+	}
+	return strings.Trim(rec.Header().Get("ETag"), `"`)
+}
+
+func TestGetSingleRange(t *testing.T) {
+	mux := newTestRouter(t)
+	putTestObject(t, mux, "/bucket/obj", "0123456789")
+
+	req := httptest.NewRequest(http.MethodGet, "/bucket/obj", nil)
+	req.Header.Set("Range", "bytes=2-5")
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusPartialContent {
+		t.Fatalf("status = %d, want 206", rec.Code)
+	}
+	if rec.Body.String() != "2345" {
+		t.Fatalf("body = %q, want %q", rec.Body.String(), "2345")
+	}
+	if got := rec.Header().Get("Content-Range"); got != "bytes 2-5/10" {
+		t.Fatalf("Content-Range = %q, want %q", got, "bytes 2-5/10")
+	}
+}
+
+func TestGetMultiRangeEmitsMultipartByteranges(t *testing.T) {
+	mux := newTestRouter(t)
+	putTestObject(t, mux, "/bucket/obj", "0123456789")
+
+	req := httptest.NewRequest(http.MethodGet, "/bucket/obj", nil)
+	req.Header.Set("Range", "bytes=0-1,5-6")
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusPartialContent {
+		t.Fatalf("status = %d, want 206", rec.Code)
+	}
+	ctype := rec.Header().Get("Content-Type")
+	if !strings.HasPrefix(ctype, "multipart/byteranges; boundary=") {
+		t.Fatalf("Content-Type = %q, want a multipart/byteranges boundary", ctype)
+	}
+	body := rec.Body.String()
+	if !strings.Contains(body, "Content-Range: bytes 0-1/10") || !strings.Contains(body, "Content-Range: bytes 5-6/10") {
+		t.Fatalf("multipart body missing expected Content-Range parts: %s", body)
+	}
+}
+
+func TestGetIfNoneMatchReturnsNotModified(t *testing.T) {
+	mux := newTestRouter(t)
+	etag := putTestObject(t, mux, "/bucket/obj", "hello")
+
+	req := httptest.NewRequest(http.MethodGet, "/bucket/obj", nil)
+	req.Header.Set("If-None-Match", `"`+etag+`"`)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotModified {
+		t.Fatalf("status = %d, want 304", rec.Code)
+	}
+}
+
+func TestGetIfMatchMismatchReturnsPreconditionFailed(t *testing.T) {
+	mux := newTestRouter(t)
+	putTestObject(t, mux, "/bucket/obj", "hello")
+
+	req := httptest.NewRequest(http.MethodGet, "/bucket/obj", nil)
+	req.Header.Set("If-Match", `"does-not-match"`)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusPreconditionFailed {
+		t.Fatalf("status = %d, want 412", rec.Code)
+	}
+}
+
+func TestPutIfNoneMatchStarRejectsOverwrite(t *testing.T) {
+	mux := newTestRouter(t)
+	putTestObject(t, mux, "/bucket/obj", "hello")
+
+	req := httptest.NewRequest(http.MethodPut, "/bucket/obj", strings.NewReader("overwrite"))
+	req.Header.Set("If-None-Match", "*")
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusPreconditionFailed {
+		t.Fatalf("status = %d, want 412", rec.Code)
+	}
+}
+
+func TestDeleteIfMatchMismatchRejected(t *testing.T) {
+	mux := newTestRouter(t)
+	putTestObject(t, mux, "/bucket/obj", "hello")
+
+	req := httptest.NewRequest(http.MethodDelete, "/bucket/obj", nil)
+	req.Header.Set("If-Match", `"does-not-match"`)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusPreconditionFailed {
+		t.Fatalf("status = %d, want 412", rec.Code)
+	}
+}
@@ -0,0 +1,375 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// S3Proxy forwards every ObjectStore call to an upstream S3-compatible
+// endpoint, letting tinys3 act as a caching/logging shim in front of
+// production storage. It speaks the same path-style wire protocol tinys3
+// itself serves, so it works against another tinys3 or a real S3/MinIO
+// endpoint that accepts unauthenticated requests. It does not perform its
+// own SigV4 signing of the upstream requests.
+type S3Proxy struct {
+	endpoint string
+	client   *http.Client
+}
+
+func NewS3Proxy(endpoint string) *S3Proxy {
+	return &S3Proxy{
+		endpoint: strings.TrimRight(endpoint, "/"),
+		client:   &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (p *S3Proxy) url(bucket, key, query string) string {
+	u := p.endpoint + "/" + bucket
+	if key != "" {
+		u += "/" + key
+	}
+	if query != "" {
+		u += "?" + query
+	}
+	return u
+}
+
+func (p *S3Proxy) do(req *http.Request, want int) error {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != want {
+		var e ErrorResponse
+		_ = xml.NewDecoder(resp.Body).Decode(&e)
+		if e.Code != "" {
+			return fmt.Errorf("upstream: %s: %s", e.Code, e.Message)
+		}
+		return fmt.Errorf("upstream: unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+//
+//
+//
+
+func (p *S3Proxy) ListBuckets() ([]BucketInfo, error) {
+	resp, err := p.client.Get(p.endpoint + "/")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("upstream ListBuckets: %s", resp.Status)
+	}
+	var out ListAllMyBucketsResult
+	if err := xml.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	buckets := make([]BucketInfo, 0, len(out.Buckets.Bucket))
+	for _, b := range out.Buckets.Bucket {
+		created, _ := time.Parse(time.RFC3339, b.CreationDate)
+		buckets = append(buckets, BucketInfo{Name: b.Name, CreationDate: created})
+	}
+	return buckets, nil
+}
+
+func (p *S3Proxy) MakeBucket(name string) error {
+	req, err := http.NewRequest(http.MethodPut, p.url(name, "", ""), nil)
+	if err != nil {
+		return err
+	}
+	return p.do(req, http.StatusOK)
+}
+
+func (p *S3Proxy) DeleteBucket(name string) error {
+	req, err := http.NewRequest(http.MethodDelete, p.url(name, "", ""), nil)
+	if err != nil {
+		return err
+	}
+	return p.do(req, http.StatusNoContent)
+}
+
+func (p *S3Proxy) ListObjectsV2(bucket, prefix, delimiter, startAfter, continuationToken string, maxKeys int) (ListV2, error) {
+	q := url.Values{"list-type": {"2"}}
+	if prefix != "" {
+		q.Set("prefix", prefix)
+	}
+	if delimiter != "" {
+		q.Set("delimiter", delimiter)
+	}
+	if startAfter != "" {
+		q.Set("start-after", startAfter)
+	}
+	if continuationToken != "" {
+		q.Set("continuation-token", continuationToken)
+	}
+	if maxKeys > 0 {
+		q.Set("max-keys", strconv.Itoa(maxKeys))
+	}
+
+	resp, err := p.client.Get(p.url(bucket, "", q.Encode()))
+	if err != nil {
+		return ListV2{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return ListV2{}, fmt.Errorf("upstream ListObjectsV2: %s", resp.Status)
+	}
+	var out ListBucketResultV2
+	if err := xml.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return ListV2{}, err
+	}
+	res := ListV2{IsTruncated: out.IsTruncated, NextContinuationToken: out.NextContinuationToken}
+	for _, c := range out.Contents {
+		mod, _ := time.Parse(time.RFC3339, c.LastModified)
+		res.Contents = append(res.Contents, ObjInfo{Key: c.Key, Size: c.Size, ETag: strings.Trim(c.ETag, `"`), LastModified: mod})
+	}
+	for _, cp := range out.CommonPrefixes {
+		res.CommonPrefixes = append(res.CommonPrefixes, cp.Prefix)
+	}
+	return res, nil
+}
+
+//
+//
+//
+
+func (p *S3Proxy) PutObject(bucket, key string, body io.Reader, n int64) (string, int64, time.Time, error) {
+	req, err := http.NewRequest(http.MethodPut, p.url(bucket, key, ""), body)
+	if err != nil {
+		return "", 0, time.Time{}, err
+	}
+	req.ContentLength = n
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", 0, time.Time{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", 0, time.Time{}, fmt.Errorf("upstream PutObject: %s", resp.Status)
+	}
+	etag := strings.Trim(resp.Header.Get("ETag"), `"`)
+	mod, _ := time.Parse(http.TimeFormat, resp.Header.Get("Last-Modified"))
+	return etag, n, mod, nil
+}
+
+func (p *S3Proxy) GetObject(bucket, key string, br *ByteRange) (io.ReadCloser, int64, string, time.Time, error) {
+	req, err := http.NewRequest(http.MethodGet, p.url(bucket, key, ""), nil)
+	if err != nil {
+		return nil, 0, "", time.Time{}, err
+	}
+	if br != nil {
+		req.Header.Set("Range", rangeHeaderValue(br))
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, 0, "", time.Time{}, err
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, 0, "", time.Time{}, os.ErrNotExist
+		}
+		return nil, 0, "", time.Time{}, fmt.Errorf("upstream GetObject: %s", resp.Status)
+	}
+	etag := strings.Trim(resp.Header.Get("ETag"), `"`)
+	mod, _ := time.Parse(http.TimeFormat, resp.Header.Get("Last-Modified"))
+	return resp.Body, resp.ContentLength, etag, mod, nil
+}
+
+func (p *S3Proxy) HeadObject(bucket, key string) (int64, string, time.Time, error) {
+	resp, err := p.client.Head(p.url(bucket, key, ""))
+	if err != nil {
+		return 0, "", time.Time{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		if resp.StatusCode == http.StatusNotFound {
+			return 0, "", time.Time{}, os.ErrNotExist
+		}
+		return 0, "", time.Time{}, fmt.Errorf("upstream HeadObject: %s", resp.Status)
+	}
+	etag := strings.Trim(resp.Header.Get("ETag"), `"`)
+	mod, _ := time.Parse(http.TimeFormat, resp.Header.Get("Last-Modified"))
+	return resp.ContentLength, etag, mod, nil
+}
+
+func (p *S3Proxy) DeleteObject(bucket, key string) error {
+	req, err := http.NewRequest(http.MethodDelete, p.url(bucket, key, ""), nil)
+	if err != nil {
+		return err
+	}
+	return p.do(req, http.StatusNoContent)
+}
+
+//
+//
+//
+
+func (p *S3Proxy) CreateMultipartUpload(bucket, key string) (string, error) {
+	req, err := http.NewRequest(http.MethodPost, p.url(bucket, key, "uploads"), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("upstream CreateMultipartUpload: %s", resp.Status)
+	}
+	var out InitiateMultipartUploadResult
+	if err := xml.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", err
+	}
+	return out.UploadID, nil
+}
+
+func (p *S3Proxy) UploadPart(bucket, key, uploadID string, partNumber int, body io.Reader, n int64) (string, error) {
+	q := url.Values{"uploadId": {uploadID}, "partNumber": {strconv.Itoa(partNumber)}}
+	req, err := http.NewRequest(http.MethodPut, p.url(bucket, key, q.Encode()), body)
+	if err != nil {
+		return "", err
+	}
+	req.ContentLength = n
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("upstream UploadPart: %s", resp.Status)
+	}
+	return strings.Trim(resp.Header.Get("ETag"), `"`), nil
+}
+
+func (p *S3Proxy) CompleteMultipartUpload(bucket, key, uploadID string, parts []CompletedPart) (string, error) {
+	body := CompleteMultipartUploadRequest{}
+	for _, part := range parts {
+		body.Part = append(body.Part, CompletedPartXML{PartNumber: part.PartNumber, ETag: part.ETag})
+	}
+	buf, err := xml.Marshal(body)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequest(http.MethodPost, p.url(bucket, key, url.Values{"uploadId": {uploadID}}.Encode()), bytes.NewReader(buf))
+	if err != nil {
+		return "", err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("upstream CompleteMultipartUpload: %s", resp.Status)
+	}
+	var out CompleteMultipartUploadResult
+	if err := xml.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", err
+	}
+	return strings.Trim(out.ETag, `"`), nil
+}
+
+func (p *S3Proxy) AbortMultipartUpload(bucket, key, uploadID string) error {
+	req, err := http.NewRequest(http.MethodDelete, p.url(bucket, key, url.Values{"uploadId": {uploadID}}.Encode()), nil)
+	if err != nil {
+		return err
+	}
+	return p.do(req, http.StatusNoContent)
+}
+
+func (p *S3Proxy) ListParts(bucket, key, uploadID string) ([]PartInfo, error) {
+	resp, err := p.client.Get(p.url(bucket, key, url.Values{"uploadId": {uploadID}}.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("upstream ListParts: %s", resp.Status)
+	}
+	var out ListPartsResult
+	if err := xml.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	parts := make([]PartInfo, 0, len(out.Part))
+	for _, part := range out.Part {
+		mod, _ := time.Parse(time.RFC3339, part.LastModified)
+		parts = append(parts, PartInfo{PartNumber: part.PartNumber, ETag: strings.Trim(part.ETag, `"`), Size: part.Size, LastModified: mod})
+	}
+	return parts, nil
+}
+
+//
+//
+//
+
+func (p *S3Proxy) CopyObject(srcBucket, srcKey, dstBucket, dstKey string) (string, time.Time, error) {
+	req, err := http.NewRequest(http.MethodPut, p.url(dstBucket, dstKey, ""), nil)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	req.Header.Set("x-amz-copy-source", "/"+srcBucket+"/"+srcKey)
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", time.Time{}, fmt.Errorf("upstream CopyObject: %s", resp.Status)
+	}
+	var out CopyObjectResult
+	if err := xml.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", time.Time{}, err
+	}
+	mod, _ := time.Parse(time.RFC3339, out.LastModified)
+	return strings.Trim(out.ETag, `"`), mod, nil
+}
+
+func (p *S3Proxy) DeleteObjects(bucket string, keys []string) ([]DeletedKey, []DeleteError, error) {
+	body := DeleteRequest{}
+	for _, k := range keys {
+		body.Object = append(body.Object, DeleteObjectID{Key: k})
+	}
+	buf, err := xml.Marshal(body)
+	if err != nil {
+		return nil, nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, p.url(bucket, "", "delete"), bytes.NewReader(buf))
+	if err != nil {
+		return nil, nil, err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("upstream DeleteObjects: %s", resp.Status)
+	}
+	var out DeleteResult
+	if err := xml.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, nil, err
+	}
+	deleted := make([]DeletedKey, 0, len(out.Deleted))
+	for _, d := range out.Deleted {
+		deleted = append(deleted, DeletedKey{Key: d.Key})
+	}
+	fails := make([]DeleteError, 0, len(out.Error))
+	for _, e := range out.Error {
+		fails = append(fails, DeleteError{Key: e.Key, Code: e.Code, Message: e.Message})
+	}
+	return deleted, fails, nil
+}
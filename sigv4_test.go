@@ -0,0 +1,355 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testAccessKeyID     = "AKIATESTACCESSKEY0000"
+	testSecretAccessKey = "test-secret-access-key"
+	testRegion          = "us-east-1"
+	testService         = "s3"
+)
+
+func testCreds(caps ...Capability) Credentials {
+	return StaticCredentials{{AccessKeyID: testAccessKeyID, SecretAccessKey: testSecretAccessKey, Capabilities: caps}}
+}
+
+// signedRequest builds a request carrying a valid header-based SigV4
+// Authorization for ts, signed with the same canonicalization helpers
+// verifyHeader checks against.
+func signedRequest(t *testing.T, method, rawURL string, ts time.Time) *http.Request {
+	t.Helper()
+	r := httptest.NewRequest(method, rawURL, nil)
+	r.Header.Set("X-Amz-Content-Sha256", "UNSIGNED-PAYLOAD")
+	r.Header.Set("X-Amz-Date", ts.UTC().Format(amzDateFormat))
+
+	dateStamp := ts.UTC().Format("20060102")
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := buildCanonicalRequest(r, signedHeaders, "UNSIGNED-PAYLOAD")
+	scope := fmt.Sprintf(credentialScopeFmt, dateStamp, testRegion, testService)
+	stringToSign := buildStringToSign(r.Header.Get("X-Amz-Date"), scope, canonicalRequest)
+	signingKey := deriveSigningKey(testSecretAccessKey, dateStamp, testRegion, testService)
+	sig := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	r.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s/%s/%s/aws4_request, SignedHeaders=%s, Signature=%s",
+		testAccessKeyID, dateStamp, testRegion, testService, signedHeaders, sig,
+	))
+	return r
+}
+
+// presignedRequest builds a request carrying a valid presigned SigV4 query
+// string for ts, expiring after ttlSeconds.
+func presignedRequest(t *testing.T, method, rawURL string, ts time.Time, ttlSeconds int) *http.Request {
+	t.Helper()
+	dateStamp := ts.UTC().Format("20060102")
+	signedHeaders := "host"
+
+	q := url.Values{}
+	q.Set("X-Amz-Algorithm", "AWS4-HMAC-SHA256")
+	q.Set("X-Amz-Credential", fmt.Sprintf("%s/%s/%s/%s/aws4_request", testAccessKeyID, dateStamp, testRegion, testService))
+	q.Set("X-Amz-Date", ts.UTC().Format(amzDateFormat))
+	q.Set("X-Amz-Expires", fmt.Sprintf("%d", ttlSeconds))
+	q.Set("X-Amz-SignedHeaders", signedHeaders)
+
+	r := httptest.NewRequest(method, rawURL+"?"+q.Encode(), nil)
+	canonicalRequest := buildCanonicalRequest(r, signedHeaders, "UNSIGNED-PAYLOAD", q)
+	scope := fmt.Sprintf(credentialScopeFmt, dateStamp, testRegion, testService)
+	stringToSign := buildStringToSign(q.Get("X-Amz-Date"), scope, canonicalRequest)
+	signingKey := deriveSigningKey(testSecretAccessKey, dateStamp, testRegion, testService)
+	sig := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+	q.Set("X-Amz-Signature", sig)
+
+	r.URL.RawQuery = q.Encode()
+	return r
+}
+
+// signedBodyRequest is like signedRequest but signs the real SHA-256 of
+// body instead of taking the UNSIGNED-PAYLOAD shortcut, for testing that
+// the signature actually binds the payload bytes.
+func signedBodyRequest(t *testing.T, method, rawURL string, ts time.Time, body string) *http.Request {
+	t.Helper()
+	sum := sha256.Sum256([]byte(body))
+	contentHash := hex.EncodeToString(sum[:])
+
+	r := httptest.NewRequest(method, rawURL, strings.NewReader(body))
+	r.Header.Set("X-Amz-Content-Sha256", contentHash)
+	r.Header.Set("X-Amz-Date", ts.UTC().Format(amzDateFormat))
+
+	dateStamp := ts.UTC().Format("20060102")
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := buildCanonicalRequest(r, signedHeaders, contentHash)
+	scope := fmt.Sprintf(credentialScopeFmt, dateStamp, testRegion, testService)
+	stringToSign := buildStringToSign(r.Header.Get("X-Amz-Date"), scope, canonicalRequest)
+	signingKey := deriveSigningKey(testSecretAccessKey, dateStamp, testRegion, testService)
+	sig := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	r.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s/%s/%s/aws4_request, SignedHeaders=%s, Signature=%s",
+		testAccessKeyID, dateStamp, testRegion, testService, signedHeaders, sig,
+	))
+	return r
+}
+
+// chunkedSignedRequest builds a PUT using the
+// STREAMING-AWS4-HMAC-SHA256-PAYLOAD encoding: a header-signed request over
+// the STREAMING marker, followed by one data chunk and the zero-length
+// trailing chunk, each carrying its own chunk-signature chained from the
+// request's own (seed) Authorization signature.
+func chunkedSignedRequest(t *testing.T, rawURL string, ts time.Time, data string) *http.Request {
+	t.Helper()
+	const streamingHash = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+
+	r := httptest.NewRequest(http.MethodPut, rawURL, nil)
+	r.Header.Set("X-Amz-Content-Sha256", streamingHash)
+	r.Header.Set("X-Amz-Date", ts.UTC().Format(amzDateFormat))
+
+	dateStamp := ts.UTC().Format("20060102")
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := buildCanonicalRequest(r, signedHeaders, streamingHash)
+	scope := fmt.Sprintf(credentialScopeFmt, dateStamp, testRegion, testService)
+	stringToSign := buildStringToSign(r.Header.Get("X-Amz-Date"), scope, canonicalRequest)
+	signingKey := deriveSigningKey(testSecretAccessKey, dateStamp, testRegion, testService)
+	seedSig := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	r.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s/%s/%s/aws4_request, SignedHeaders=%s, Signature=%s",
+		testAccessKeyID, dateStamp, testRegion, testService, signedHeaders, seedSig,
+	))
+
+	chunkSign := func(prevSig, dataHash string) string {
+		sts := strings.Join([]string{
+			"AWS4-HMAC-SHA256-PAYLOAD",
+			r.Header.Get("X-Amz-Date"),
+			scope,
+			prevSig,
+			dataHash,
+		}, "\n")
+		return hex.EncodeToString(hmacSHA256(signingKey, sts))
+	}
+
+	dataSum := sha256.Sum256([]byte(data))
+	chunk1Sig := chunkSign(seedSig, hex.EncodeToString(dataSum[:]))
+	emptySum := sha256.Sum256(nil)
+	finalSig := chunkSign(chunk1Sig, hex.EncodeToString(emptySum[:]))
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "%x;chunk-signature=%s\r\n%s\r\n", len(data), chunk1Sig, data)
+	fmt.Fprintf(&body, "0;chunk-signature=%s\r\n\r\n", finalSig)
+
+	r.Body = io.NopCloser(strings.NewReader(body.String()))
+	return r
+}
+
+func TestAuthenticateHeaderValid(t *testing.T) {
+	r := signedRequest(t, http.MethodGet, "http://example.com/mybucket/key", time.Now())
+	if err := authenticate(testCreds(), r); err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+}
+
+func TestAuthenticateHeaderExpired(t *testing.T) {
+	r := signedRequest(t, http.MethodGet, "http://example.com/mybucket/key", time.Now().Add(-time.Hour))
+	if err := authenticate(testCreds(), r); err == nil {
+		t.Fatal("authenticate: expected error for a timestamp far outside the allowed skew")
+	}
+}
+
+func TestAuthenticateHeaderClockSkew(t *testing.T) {
+	r := signedRequest(t, http.MethodGet, "http://example.com/mybucket/key", time.Now().Add(20*time.Minute))
+	if err := authenticate(testCreds(), r); err == nil {
+		t.Fatal("authenticate: expected error for a future-skewed timestamp")
+	}
+}
+
+func TestAuthenticateHeaderTamperedSignature(t *testing.T) {
+	r := signedRequest(t, http.MethodGet, "http://example.com/mybucket/key", time.Now())
+	r.URL.Path = "/mybucket/other-key" // mutate the request after signing
+	if err := authenticate(testCreds(), r); err == nil {
+		t.Fatal("authenticate: expected signature mismatch after tampering with the signed path")
+	}
+}
+
+func TestAuthenticateHeaderUnknownAccessKey(t *testing.T) {
+	r := signedRequest(t, http.MethodGet, "http://example.com/mybucket/key", time.Now())
+	r.Header.Set("Authorization", strings.Replace(r.Header.Get("Authorization"), testAccessKeyID, "AKIAUNKNOWNACCESSKEY0", 1))
+	if err := authenticate(testCreds(), r); err == nil {
+		t.Fatal("authenticate: expected error for an unrecognized access key")
+	}
+}
+
+func TestAuthenticatePresignedValid(t *testing.T) {
+	r := presignedRequest(t, http.MethodGet, "http://example.com/mybucket/key", time.Now(), 900)
+	if err := authenticate(testCreds(), r); err != nil {
+		t.Fatalf("authenticate presigned: %v", err)
+	}
+}
+
+func TestAuthenticatePresignedExpired(t *testing.T) {
+	r := presignedRequest(t, http.MethodGet, "http://example.com/mybucket/key", time.Now().Add(-time.Hour), 900)
+	if err := authenticate(testCreds(), r); err == nil {
+		t.Fatal("authenticate: expected error for an expired presigned URL")
+	}
+}
+
+func TestAuthenticatePresignedTamperedSignature(t *testing.T) {
+	r := presignedRequest(t, http.MethodGet, "http://example.com/mybucket/key", time.Now(), 900)
+	q := r.URL.Query()
+	q.Set("X-Amz-Signature", strings.Repeat("0", len(q.Get("X-Amz-Signature"))))
+	r.URL.RawQuery = q.Encode()
+	if err := authenticate(testCreds(), r); err == nil {
+		t.Fatal("authenticate: expected signature mismatch for a tampered presigned signature")
+	}
+}
+
+func TestActionForBatchDelete(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "http://example.com/mybucket?delete", nil)
+	if got := actionFor(r, ""); got != "delete" {
+		t.Fatalf("actionFor(POST ?delete) = %q, want %q", got, "delete")
+	}
+}
+
+func TestAuthenticateBatchDeleteRequiresDeleteCapability(t *testing.T) {
+	creds := testCreds(Capability{Bucket: "mybucket", Write: true})
+	r := signedRequest(t, http.MethodPost, "http://example.com/mybucket?delete", time.Now())
+	if err := authenticate(creds, r); err == nil {
+		t.Fatal("authenticate: expected error for a write-only credential batch-deleting")
+	}
+
+	creds = testCreds(Capability{Bucket: "mybucket", Delete: true})
+	r = signedRequest(t, http.MethodPost, "http://example.com/mybucket?delete", time.Now())
+	if err := authenticate(creds, r); err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+}
+
+func TestAuthenticateCopySourceRequiresReadCapability(t *testing.T) {
+	creds := testCreds(Capability{Bucket: "dst", Write: true})
+	r := signedRequest(t, http.MethodPut, "http://example.com/dst/out", time.Now())
+	r.Header.Set("x-amz-copy-source", "/src/secret")
+	if err := authenticate(creds, r); err == nil {
+		t.Fatal("authenticate: expected error when the credential lacks read on the copy source bucket")
+	}
+}
+
+func TestAuthenticateCopySourceAllowedWithReadCapability(t *testing.T) {
+	creds := testCreds(
+		Capability{Bucket: "dst", Write: true},
+		Capability{Bucket: "src", Read: true},
+	)
+	r := signedRequest(t, http.MethodPut, "http://example.com/dst/out", time.Now())
+	r.Header.Set("x-amz-copy-source", "/src/secret")
+	if err := authenticate(creds, r); err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+}
+
+// TestAuthenticateRejectsSubstitutedPayload is a regression test: a signed
+// request's payload hash used to be taken on faith from the client-declared
+// X-Amz-Content-Sha256 header and never checked against the bytes the
+// handler actually reads, so a proxy or replay could swap in different
+// object content while keeping the original signature intact.
+func TestAuthenticateRejectsSubstitutedPayload(t *testing.T) {
+	r := signedBodyRequest(t, http.MethodPut, "http://example.com/mybucket/key", time.Now(), "original bytes")
+	// Simulate an in-flight substitution: the signature covers the
+	// declared X-Amz-Content-Sha256 header, not the body bytes directly,
+	// so swapping r.Body before authenticate runs doesn't break the
+	// header-level signature check by itself.
+	r.Body = io.NopCloser(strings.NewReader("substituted bytes!!"))
+	if err := authenticate(testCreds(), r); err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	if _, err := io.ReadAll(r.Body); !errors.Is(err, errPayloadSHA256Mismatch) {
+		t.Fatalf("reading substituted body: err = %v, want errPayloadSHA256Mismatch", err)
+	}
+}
+
+func TestAuthenticateAcceptsMatchingPayload(t *testing.T) {
+	r := signedBodyRequest(t, http.MethodPut, "http://example.com/mybucket/key", time.Now(), "hello world")
+	if err := authenticate(testCreds(), r); err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	got, err := io.ReadAll(r.Body)
+	if err != nil {
+		t.Fatalf("reading body: %v", err)
+	}
+	if string(got) != "hello world" {
+		t.Fatalf("body = %q, want %q", got, "hello world")
+	}
+}
+
+func TestAuthenticateStreamingChunkValid(t *testing.T) {
+	r := chunkedSignedRequest(t, "http://example.com/mybucket/key", time.Now(), "hello chunked world")
+	if err := authenticate(testCreds(), r); err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	got, err := io.ReadAll(r.Body)
+	if err != nil {
+		t.Fatalf("reading de-chunked body: %v", err)
+	}
+	if string(got) != "hello chunked world" {
+		t.Fatalf("body = %q, want %q", got, "hello chunked world")
+	}
+}
+
+// TestAuthenticateStreamingChunkTamperedDataRejected is a regression test:
+// chunkedPayloadReader used to strip STREAMING-AWS4-HMAC-SHA256-PAYLOAD
+// chunk framing without ever re-verifying a chunk's declared signature, so
+// a tampered chunk (same size, different bytes) passed through untouched.
+func TestAuthenticateStreamingChunkTamperedDataRejected(t *testing.T) {
+	r := chunkedSignedRequest(t, "http://example.com/mybucket/key", time.Now(), "hello chunked world")
+	raw, err := io.ReadAll(r.Body)
+	if err != nil {
+		t.Fatalf("reading raw chunked body: %v", err)
+	}
+	tampered := strings.Replace(string(raw), "hello", "jello", 1)
+	r.Body = io.NopCloser(strings.NewReader(tampered))
+
+	if err := authenticate(testCreds(), r); err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	if _, err := io.ReadAll(r.Body); !errors.Is(err, errChunkSignatureMismatch) {
+		t.Fatalf("reading tampered chunked body: err = %v, want errChunkSignatureMismatch", err)
+	}
+}
+
+func TestAuthenticateWebDAVCopyRequiresDestinationWriteCapability(t *testing.T) {
+	creds := testCreds(Capability{Bucket: "src", Read: true})
+	r := signedRequest(t, "COPY", "http://example.com/src/secret", time.Now())
+	r.Header.Set("Destination", "/dst/exfiltrated")
+	if err := authenticate(creds, r); err == nil {
+		t.Fatal("authenticate: expected error when the credential lacks write on the COPY destination bucket")
+	}
+}
+
+func TestAuthenticateWebDAVCopyRequiresSourceReadCapability(t *testing.T) {
+	creds := testCreds(Capability{Bucket: "dst", Write: true})
+	r := signedRequest(t, "COPY", "http://example.com/src/secret", time.Now())
+	r.Header.Set("Destination", "/dst/exfiltrated")
+	if err := authenticate(creds, r); err == nil {
+		t.Fatal("authenticate: expected error when the credential lacks read on the COPY source bucket")
+	}
+}
+
+func TestAuthenticateWebDAVCopyAllowedWithSourceReadAndDestinationWrite(t *testing.T) {
+	creds := testCreds(
+		Capability{Bucket: "src", Read: true},
+		Capability{Bucket: "dst", Write: true},
+	)
+	r := signedRequest(t, "COPY", "http://example.com/src/secret", time.Now())
+	r.Header.Set("Destination", "/dst/exfiltrated")
+	if err := authenticate(creds, r); err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+// conformanceStores returns every registered backend that can run without
+// external dependencies (i.e. not S3Proxy, which needs a live upstream).
+func conformanceStores(t *testing.T) map[string]ObjectStore {
+	t.Helper()
+	return map[string]ObjectStore{
+		"LocalFS": NewLocalFS(t.TempDir()),
+		"MemFS":   NewMemFS(),
+	}
+}
+
+func TestObjectStoreConformance(t *testing.T) {
+	for name, store := range conformanceStores(t) {
+		t.Run(name, func(t *testing.T) {
+			t.Run("PutGetRoundTrip", func(t *testing.T) { testPutGetRoundTrip(t, store) })
+			t.Run("OverwriteSemantics", func(t *testing.T) { testOverwriteSemantics(t, store) })
+			t.Run("RangedReads", func(t *testing.T) { testRangedReads(t, store) })
+			t.Run("ListingPagination", func(t *testing.T) { testListingPagination(t, store) })
+			t.Run("DelimiterGrouping", func(t *testing.T) { testDelimiterGrouping(t, store) })
+			t.Run("CopyObject", func(t *testing.T) { testCopyObject(t, store) })
+			t.Run("DeleteObjects", func(t *testing.T) { testDeleteObjects(t, store) })
+			t.Run("MultipartUpload", func(t *testing.T) { testMultipartUpload(t, store) })
+			t.Run("AbortMultipartUpload", func(t *testing.T) { testAbortMultipartUpload(t, store) })
+		})
+	}
+}
+
+func testPutGetRoundTrip(t *testing.T, store ObjectStore) {
+	const bucket = "conformance-roundtrip"
+	want := []byte("hello, tinys3")
+
+	etag, n, _, err := store.PutObject(bucket, "greeting.txt", bytes.NewReader(want), int64(len(want)))
+	if err != nil {
+		t.Fatalf("PutObject: %v", err)
+	}
+	if n != int64(len(want)) {
+		t.Fatalf("PutObject size = %d, want %d", n, len(want))
+	}
+
+	rc, size, gotETag, _, err := store.GetObject(bucket, "greeting.txt", nil)
+	if err != nil {
+		t.Fatalf("GetObject: %v", err)
+	}
+	defer rc.Close()
+	got, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("read object body: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("GetObject body = %q, want %q", got, want)
+	}
+	if size != int64(len(want)) {
+		t.Fatalf("GetObject size = %d, want %d", size, len(want))
+	}
+	if gotETag != etag {
+		t.Fatalf("GetObject etag = %q, want %q", gotETag, etag)
+	}
+}
+
+func testOverwriteSemantics(t *testing.T, store ObjectStore) {
+	const bucket = "conformance-overwrite"
+
+	if _, _, _, err := store.PutObject(bucket, "k", bytes.NewReader([]byte("v1")), 2); err != nil {
+		t.Fatalf("PutObject v1: %v", err)
+	}
+	if _, _, _, err := store.PutObject(bucket, "k", bytes.NewReader([]byte("v2-longer")), 9); err != nil {
+		t.Fatalf("PutObject v2: %v", err)
+	}
+
+	rc, size, _, _, err := store.GetObject(bucket, "k", nil)
+	if err != nil {
+		t.Fatalf("GetObject: %v", err)
+	}
+	defer rc.Close()
+	got, _ := io.ReadAll(rc)
+	if string(got) != "v2-longer" {
+		t.Fatalf("GetObject after overwrite = %q, want %q", got, "v2-longer")
+	}
+	if size != 9 {
+		t.Fatalf("GetObject size after overwrite = %d, want 9", size)
+	}
+}
+
+func testRangedReads(t *testing.T, store ObjectStore) {
+	const bucket = "conformance-range"
+	data := []byte("0123456789")
+	if _, _, _, err := store.PutObject(bucket, "k", bytes.NewReader(data), int64(len(data))); err != nil {
+		t.Fatalf("PutObject: %v", err)
+	}
+
+	ranges, _ := ParseRange("bytes=2-5")
+	rc, size, _, _, err := store.GetObject(bucket, "k", &ranges[0])
+	if err != nil {
+		t.Fatalf("GetObject ranged: %v", err)
+	}
+	defer rc.Close()
+	got, _ := io.ReadAll(rc)
+	if string(got) != "2345" {
+		t.Fatalf("ranged GetObject body = %q, want %q", got, "2345")
+	}
+	if size != 4 {
+		t.Fatalf("ranged GetObject size = %d, want 4", size)
+	}
+}
+
+func testListingPagination(t *testing.T, store ObjectStore) {
+	const bucket = "conformance-paginate"
+	keys := []string{"a", "b", "c", "d", "e"}
+	for _, k := range keys {
+		if _, _, _, err := store.PutObject(bucket, k, bytes.NewReader([]byte(k)), 1); err != nil {
+			t.Fatalf("PutObject %q: %v", k, err)
+		}
+	}
+
+	res, err := store.ListObjectsV2(bucket, "", "", "", "", 2)
+	if err != nil {
+		t.Fatalf("ListObjectsV2: %v", err)
+	}
+	if len(res.Contents) != 2 {
+		t.Fatalf("first page len = %d, want 2", len(res.Contents))
+	}
+	if !res.IsTruncated {
+		t.Fatalf("first page IsTruncated = false, want true")
+	}
+
+	seen := map[string]bool{}
+	for _, c := range res.Contents {
+		seen[c.Key] = true
+	}
+	for cont := res.NextContinuationToken; cont != ""; {
+		res, err = store.ListObjectsV2(bucket, "", "", "", cont, 2)
+		if err != nil {
+			t.Fatalf("ListObjectsV2 continuation: %v", err)
+		}
+		for _, c := range res.Contents {
+			seen[c.Key] = true
+		}
+		cont = res.NextContinuationToken
+	}
+	if len(seen) != len(keys) {
+		t.Fatalf("paginated through %d distinct keys, want %d", len(seen), len(keys))
+	}
+}
+
+func testDelimiterGrouping(t *testing.T, store ObjectStore) {
+	const bucket = "conformance-delimiter"
+	for _, k := range []string{"a/1", "a/2", "b/1", "top"} {
+		if _, _, _, err := store.PutObject(bucket, k, bytes.NewReader([]byte(k)), int64(len(k))); err != nil {
+			t.Fatalf("PutObject %q: %v", k, err)
+		}
+	}
+
+	res, err := store.ListObjectsV2(bucket, "", "/", "", "", 1000)
+	if err != nil {
+		t.Fatalf("ListObjectsV2: %v", err)
+	}
+	if len(res.Contents) != 1 || res.Contents[0].Key != "top" {
+		t.Fatalf("Contents = %v, want just %q", res.Contents, "top")
+	}
+	if len(res.CommonPrefixes) != 2 {
+		t.Fatalf("CommonPrefixes = %v, want 2 entries", res.CommonPrefixes)
+	}
+}
+
+func testCopyObject(t *testing.T, store ObjectStore) {
+	const srcBucket, dstBucket = "conformance-copy-src", "conformance-copy-dst"
+	want := []byte("copy me")
+
+	srcETag, _, _, err := store.PutObject(srcBucket, "src-key", bytes.NewReader(want), int64(len(want)))
+	if err != nil {
+		t.Fatalf("PutObject: %v", err)
+	}
+
+	dstETag, _, err := store.CopyObject(srcBucket, "src-key", dstBucket, "dst-key")
+	if err != nil {
+		t.Fatalf("CopyObject: %v", err)
+	}
+	if dstETag != srcETag {
+		t.Fatalf("CopyObject etag = %q, want %q", dstETag, srcETag)
+	}
+
+	rc, _, _, _, err := store.GetObject(dstBucket, "dst-key", nil)
+	if err != nil {
+		t.Fatalf("GetObject copy: %v", err)
+	}
+	defer rc.Close()
+	got, _ := io.ReadAll(rc)
+	if !bytes.Equal(got, want) {
+		t.Fatalf("copied object body = %q, want %q", got, want)
+	}
+
+	if _, _, _, _, err := store.GetObject(srcBucket, "src-key", nil); err != nil {
+		t.Fatalf("source object should be unaffected by CopyObject: %v", err)
+	}
+}
+
+func testDeleteObjects(t *testing.T, store ObjectStore) {
+	const bucket = "conformance-delete-batch"
+	for _, k := range []string{"keep", "gone-1", "gone-2"} {
+		if _, _, _, err := store.PutObject(bucket, k, bytes.NewReader([]byte(k)), int64(len(k))); err != nil {
+			t.Fatalf("PutObject %q: %v", k, err)
+		}
+	}
+
+	deleted, fails, err := store.DeleteObjects(bucket, []string{"gone-1", "gone-2", "never-existed"})
+	if err != nil {
+		t.Fatalf("DeleteObjects: %v", err)
+	}
+	if len(fails) != 0 {
+		t.Fatalf("DeleteObjects errors = %v, want none (delete is idempotent on missing keys)", fails)
+	}
+	gotKeys := map[string]bool{}
+	for _, d := range deleted {
+		gotKeys[d.Key] = true
+	}
+	for _, want := range []string{"gone-1", "gone-2", "never-existed"} {
+		if !gotKeys[want] {
+			t.Fatalf("DeleteObjects did not report %q as deleted", want)
+		}
+	}
+
+	if _, _, _, _, err := store.GetObject(bucket, "keep", nil); err != nil {
+		t.Fatalf("GetObject %q should still exist after batch delete of other keys: %v", "keep", err)
+	}
+	if _, _, _, _, err := store.GetObject(bucket, "gone-1", nil); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("GetObject %q after delete: err = %v, want os.ErrNotExist", "gone-1", err)
+	}
+}
+
+func testMultipartUpload(t *testing.T, store ObjectStore) {
+	const bucket = "conformance-multipart"
+	part1, part2 := []byte("the first part of the object "), []byte("and the tail")
+
+	uploadID, err := store.CreateMultipartUpload(bucket, "assembled")
+	if err != nil {
+		t.Fatalf("CreateMultipartUpload: %v", err)
+	}
+
+	etag1, err := store.UploadPart(bucket, "assembled", uploadID, 1, bytes.NewReader(part1), int64(len(part1)))
+	if err != nil {
+		t.Fatalf("UploadPart 1: %v", err)
+	}
+	etag2, err := store.UploadPart(bucket, "assembled", uploadID, 2, bytes.NewReader(part2), int64(len(part2)))
+	if err != nil {
+		t.Fatalf("UploadPart 2: %v", err)
+	}
+
+	parts, err := store.ListParts(bucket, "assembled", uploadID)
+	if err != nil {
+		t.Fatalf("ListParts: %v", err)
+	}
+	if len(parts) != 2 {
+		t.Fatalf("ListParts returned %d parts, want 2", len(parts))
+	}
+
+	etag, err := store.CompleteMultipartUpload(bucket, "assembled", uploadID, []CompletedPart{
+		{PartNumber: 1, ETag: etag1},
+		{PartNumber: 2, ETag: etag2},
+	})
+	if err != nil {
+		t.Fatalf("CompleteMultipartUpload: %v", err)
+	}
+	if !strings.HasSuffix(etag, "-2") {
+		t.Fatalf("CompleteMultipartUpload etag = %q, want a multipart etag ending in -2", etag)
+	}
+
+	rc, size, _, _, err := store.GetObject(bucket, "assembled", nil)
+	if err != nil {
+		t.Fatalf("GetObject assembled: %v", err)
+	}
+	defer rc.Close()
+	got, _ := io.ReadAll(rc)
+	want := append(append([]byte{}, part1...), part2...)
+	if !bytes.Equal(got, want) {
+		t.Fatalf("assembled object size = %d, want %d", len(got), len(want))
+	}
+	if size != int64(len(want)) {
+		t.Fatalf("GetObject size = %d, want %d", size, len(want))
+	}
+
+	if _, err := store.ListParts(bucket, "assembled", uploadID); !errors.Is(err, ErrNoSuchUpload) {
+		t.Fatalf("ListParts after complete: err = %v, want ErrNoSuchUpload", err)
+	}
+}
+
+func testAbortMultipartUpload(t *testing.T, store ObjectStore) {
+	const bucket = "conformance-multipart-abort"
+
+	uploadID, err := store.CreateMultipartUpload(bucket, "abandoned")
+	if err != nil {
+		t.Fatalf("CreateMultipartUpload: %v", err)
+	}
+	if _, err := store.UploadPart(bucket, "abandoned", uploadID, 1, bytes.NewReader([]byte("x")), 1); err != nil {
+		t.Fatalf("UploadPart: %v", err)
+	}
+	if err := store.AbortMultipartUpload(bucket, "abandoned", uploadID); err != nil {
+		t.Fatalf("AbortMultipartUpload: %v", err)
+	}
+	if _, err := store.ListParts(bucket, "abandoned", uploadID); !errors.Is(err, ErrNoSuchUpload) {
+		t.Fatalf("ListParts after abort: err = %v, want ErrNoSuchUpload", err)
+	}
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -13,53 +15,61 @@ type ByteRange struct {
 	Length int64
 }
 
-func ParseRange(h string) (*ByteRange, int) {
+// ParseRange parses a Range header into one ByteRange per comma-separated
+// spec (e.g. "bytes=0-99,200-299"), so callers can tell a single-range
+// request from a multi-range one that must be served as
+// multipart/byteranges. It returns (nil, http.StatusOK) when h is empty,
+// and (nil, http.StatusRequestedRangeNotSatisfiable) when h is malformed.
+func ParseRange(h string) ([]ByteRange, int) {
 	if h == "" {
 		return nil, http.StatusOK
 	}
-	// Expect: bytes=start-end
+	// Expect: bytes=start-end[,start-end...]
 	if !strings.HasPrefix(h, "bytes=") {
 		return nil, http.StatusRequestedRangeNotSatisfiable
 	}
 	val := strings.TrimPrefix(h, "bytes=")
-	parts := strings.Split(val, ",")
-	if len(parts) != 1 {
-		return nil, http.StatusRequestedRangeNotSatisfiable
-	}
-	se := strings.SplitN(strings.TrimSpace(parts[0]), "-", 2)
-	if len(se) != 2 {
-		return nil, http.StatusRequestedRangeNotSatisfiable
-	}
-	var br ByteRange
-	if se[0] == "" {
-		// suffix length
-		l, err := strconv.ParseInt(se[1], 10, 64)
-		if err != nil || l <= 0 {
+	specs := strings.Split(val, ",")
+	ranges := make([]ByteRange, 0, len(specs))
+	for _, spec := range specs {
+		se := strings.SplitN(strings.TrimSpace(spec), "-", 2)
+		if len(se) != 2 {
+			return nil, http.StatusRequestedRangeNotSatisfiable
+		}
+		var br ByteRange
+		if se[0] == "" {
+			// suffix length
+			l, err := strconv.ParseInt(se[1], 10, 64)
+			if err != nil || l <= 0 {
+				return nil, http.StatusRequestedRangeNotSatisfiable
+			}
+			br.Start = -1 // suffix marker
+			br.End = -1
+			br.Length = l
+			ranges = append(ranges, br)
+			continue
+		}
+		start, err := strconv.ParseInt(se[0], 10, 64)
+		if err != nil || start < 0 {
+			return nil, http.StatusRequestedRangeNotSatisfiable
+		}
+		if se[1] == "" {
+			br.Start = start
+			br.End = -1
+			br.Length = -1
+			ranges = append(ranges, br)
+			continue
+		}
+		end, err := strconv.ParseInt(se[1], 10, 64)
+		if err != nil || end < start {
 			return nil, http.StatusRequestedRangeNotSatisfiable
 		}
-		br.Start = -1 // suffix marker
-		br.End = -1
-		br.Length = l
-		return &br, http.StatusPartialContent
-	}
-	start, err := strconv.ParseInt(se[0], 10, 64)
-	if err != nil || start < 0 {
-		return nil, http.StatusRequestedRangeNotSatisfiable
-	}
-	if se[1] == "" {
 		br.Start = start
-		br.End = -1
-		br.Length = -1
-		return &br, http.StatusPartialContent
-	}
-	end, err := strconv.ParseInt(se[1], 10, 64)
-	if err != nil || end < start {
-		return nil, http.StatusRequestedRangeNotSatisfiable
+		br.End = end
+		br.Length = end - start + 1
+		ranges = append(ranges, br)
 	}
-	br.Start = start
-	br.End = end
-	br.Length = end - start + 1
-	return &br, http.StatusPartialContent
+	return ranges, http.StatusPartialContent
 }
 
 func (br *ByteRange) ContentRange(objSize int64) string {
@@ -83,3 +93,26 @@ func (br *ByteRange) ContentRange(objSize int64) string {
 	}
 	return fmt.Sprintf("bytes %d-%d/%d", start, end, objSize)
 }
+
+// rangeHeaderValue renders br back into the value of a Range header, the
+// inverse of ParseRange. Used by S3Proxy to forward ranged reads upstream.
+func rangeHeaderValue(br *ByteRange) string {
+	switch {
+	case br.Start == -1: // suffix
+		return fmt.Sprintf("bytes=-%d", br.Length)
+	case br.End == -1: // open-ended
+		return fmt.Sprintf("bytes=%d-", br.Start)
+	default:
+		return fmt.Sprintf("bytes=%d-%d", br.Start, br.End)
+	}
+}
+
+// randomHex returns n random bytes hex-encoded, used for generating
+// multipart upload IDs.
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,375 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NewWebDAVHandler exposes objectStore over a reduced subset of RFC 4918
+// (PROPFIND/PROPPATCH/MKCOL/COPY/MOVE/DELETE/GET/PUT/LOCK/UNLOCK, depth
+// 0/1), so non-S3 clients (macOS Finder, davfs2, editors) can mount a
+// tinys3 root as a filesystem-like share, mirroring how projects like
+// Arvados' keep-web expose the same object store over both S3 and WebDAV.
+//
+// A bucket is a top-level collection; "/" inside a key is the directory
+// delimiter, matching ListObjectsV2's own delimiter semantics. A path
+// denotes a collection if it ends in "/" (or is the bucket root); anything
+// else is an object.
+//
+// creds is enforced exactly as it is for NewRouter: if non-nil, every
+// request must carry a valid SigV4 signature, so locking down the S3
+// endpoint with --access-key/--secret-key locks down WebDAV too.
+func NewWebDAVHandler(objectStore ObjectStore, creds Credentials) http.Handler {
+	h := &webdavHandler{
+		store: objectStore,
+		locks: map[string]string{},
+	}
+	return requireSigV4(creds, h)
+}
+
+// dirKeepName is the hidden placeholder object MKCOL writes to mark an
+// otherwise-empty collection as existing. It cannot be the bare directory
+// key itself: LocalFS resolves keys through filepath.Join, which silently
+// strips the trailing slash, so "newdir/" and "newdir" would otherwise
+// collide on disk and turn the directory into a plain file.
+const dirKeepName = ".dirkeep"
+
+type webdavHandler struct {
+	store ObjectStore
+
+	mu    sync.Mutex
+	locks map[string]string // path -> lock token; advisory only, not enforced
+}
+
+func (h *webdavHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "PROPFIND":
+		h.propfind(w, r)
+	case "PROPPATCH":
+		h.proppatch(w, r)
+	case "MKCOL":
+		h.mkcol(w, r)
+	case http.MethodGet, http.MethodHead:
+		h.get(w, r)
+	case http.MethodPut:
+		h.put(w, r)
+	case http.MethodDelete:
+		h.delete(w, r)
+	case "COPY":
+		h.copyOrMove(w, r, false)
+	case "MOVE":
+		h.copyOrMove(w, r, true)
+	case "LOCK":
+		h.lock(w, r)
+	case "UNLOCK":
+		h.unlock(w, r)
+	case http.MethodOptions:
+		w.Header().Set("DAV", "1,2")
+		w.Header().Set("Allow", "OPTIONS, GET, HEAD, PUT, DELETE, PROPFIND, PROPPATCH, MKCOL, COPY, MOVE, LOCK, UNLOCK")
+		w.WriteHeader(http.StatusOK)
+	default:
+		writeS3Error(w, http.StatusMethodNotAllowed, "MethodNotAllowed", "")
+	}
+}
+
+// davPathParts splits a WebDAV request path into bucket and key, keeping
+// any trailing slash on key so callers can distinguish collections from
+// objects.
+func davPathParts(urlPath string) (bucket, key string) {
+	trimmed := strings.TrimPrefix(urlPath, "/")
+	if trimmed == "" {
+		return "", ""
+	}
+	bucket, rest, found := strings.Cut(trimmed, "/")
+	if !found {
+		return bucket, ""
+	}
+	return bucket, rest
+}
+
+//
+//
+//
+
+func (h *webdavHandler) propfind(w http.ResponseWriter, r *http.Request) {
+	depth := r.Header.Get("Depth")
+	if depth == "" {
+		depth = "1"
+	}
+	bucket, key := davPathParts(r.URL.Path)
+
+	var responses []davResponse
+
+	switch {
+	case bucket == "":
+		responses = append(responses, collectionResponse("/", time.Time{}))
+		if depth != "0" {
+			buckets, err := h.store.ListBuckets()
+			if err != nil {
+				writeS3Error(w, http.StatusInternalServerError, "InternalError", err.Error())
+				return
+			}
+			for _, b := range buckets {
+				responses = append(responses, collectionResponse("/"+b.Name+"/", b.CreationDate))
+			}
+		}
+
+	case key == "" || strings.HasSuffix(key, "/"):
+		responses = append(responses, collectionResponse(r.URL.Path, time.Time{}))
+		if depth != "0" {
+			res, err := h.store.ListObjectsV2(bucket, key, "/", "", "", 10000)
+			if err != nil {
+				writeFsErr(w, err)
+				return
+			}
+			for _, p := range res.CommonPrefixes {
+				responses = append(responses, collectionResponse("/"+bucket+"/"+p, time.Time{}))
+			}
+			for _, c := range res.Contents {
+				if strings.HasSuffix(c.Key, "/"+dirKeepName) {
+					continue // the directory's own placeholder object
+				}
+				responses = append(responses, fileResponse("/"+bucket+"/"+c.Key, c.Size, c.ETag, c.LastModified))
+			}
+		}
+
+	default:
+		size, etag, mod, err := h.store.HeadObject(bucket, key)
+		if err != nil {
+			writeFsErr(w, err)
+			return
+		}
+		responses = append(responses, fileResponse(r.URL.Path, size, etag, mod))
+	}
+
+	ms := davMultistatus{DAVAttr: "DAV:", Response: responses}
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	enc.Encode(ms)
+}
+
+// proppatch has nothing to persist custom properties into, so it just
+// acknowledges the request without storing anything.
+func (h *webdavHandler) proppatch(w http.ResponseWriter, r *http.Request) {
+	ms := davMultistatus{
+		DAVAttr: "DAV:",
+		Response: []davResponse{{
+			Href:     r.URL.Path,
+			Propstat: davPropstat{Status: "HTTP/1.1 200 OK"},
+		}},
+	}
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	xml.NewEncoder(w).Encode(ms)
+}
+
+func (h *webdavHandler) mkcol(w http.ResponseWriter, r *http.Request) {
+	bucket, key := davPathParts(r.URL.Path)
+	if bucket == "" {
+		writeS3Error(w, http.StatusConflict, "InvalidBucketName", "")
+		return
+	}
+	if key == "" {
+		if err := h.store.MakeBucket(bucket); err != nil {
+			writeFsErr(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		return
+	}
+	dirKey := strings.TrimSuffix(key, "/") + "/"
+	if _, _, _, err := h.store.PutObject(bucket, dirKey+dirKeepName, strings.NewReader(""), 0); err != nil {
+		writeFsErr(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (h *webdavHandler) get(w http.ResponseWriter, r *http.Request) {
+	bucket, key := davPathParts(r.URL.Path)
+	if bucket == "" || key == "" || strings.HasSuffix(key, "/") {
+		writeS3Error(w, http.StatusMethodNotAllowed, "MethodNotAllowed", "GET on a collection is not supported")
+		return
+	}
+	rc, size, etag, mod, err := h.store.GetObject(bucket, key, nil)
+	if err != nil {
+		writeFsErr(w, err)
+		return
+	}
+	defer rc.Close()
+
+	w.Header().Set("ETag", fmt.Sprintf("\"%s\"", etag))
+	w.Header().Set("Last-Modified", mod.UTC().Format(http.TimeFormat))
+	w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	if r.Method == http.MethodHead {
+		return
+	}
+	io.Copy(w, rc)
+}
+
+func (h *webdavHandler) put(w http.ResponseWriter, r *http.Request) {
+	bucket, key := davPathParts(r.URL.Path)
+	if bucket == "" || key == "" || strings.HasSuffix(key, "/") {
+		writeS3Error(w, http.StatusConflict, "InvalidRequest", "cannot PUT a collection")
+		return
+	}
+	_ = h.store.MakeBucket(bucket)
+	etag, _, mod, err := h.store.PutObject(bucket, key, r.Body, r.ContentLength)
+	if err != nil {
+		writeFsErr(w, err)
+		return
+	}
+	w.Header().Set("ETag", fmt.Sprintf("\"%s\"", etag))
+	w.Header().Set("Last-Modified", mod.UTC().Format(http.TimeFormat))
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (h *webdavHandler) delete(w http.ResponseWriter, r *http.Request) {
+	bucket, key := davPathParts(r.URL.Path)
+	if bucket == "" {
+		writeS3Error(w, http.StatusMethodNotAllowed, "MethodNotAllowed", "")
+		return
+	}
+	if key == "" {
+		if err := h.store.DeleteBucket(bucket); err != nil {
+			writeFsErr(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if err := h.store.DeleteObject(bucket, strings.TrimSuffix(key, "/")); err != nil {
+		writeFsErr(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *webdavHandler) copyOrMove(w http.ResponseWriter, r *http.Request, move bool) {
+	dest := r.Header.Get("Destination")
+	if dest == "" {
+		writeS3Error(w, http.StatusBadRequest, "InvalidRequest", "missing Destination header")
+		return
+	}
+	u, err := url.Parse(dest)
+	if err != nil {
+		writeS3Error(w, http.StatusBadRequest, "InvalidRequest", err.Error())
+		return
+	}
+
+	srcBucket, srcKey := davPathParts(r.URL.Path)
+	dstBucket, dstKey := davPathParts(u.Path)
+	if srcBucket == "" || srcKey == "" || dstBucket == "" || dstKey == "" {
+		writeS3Error(w, http.StatusBadRequest, "InvalidRequest", "COPY/MOVE require object paths")
+		return
+	}
+
+	if _, _, err := h.store.CopyObject(srcBucket, srcKey, dstBucket, dstKey); err != nil {
+		writeFsErr(w, err)
+		return
+	}
+	if move {
+		if err := h.store.DeleteObject(srcBucket, srcKey); err != nil {
+			writeFsErr(w, err)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+// lock and unlock satisfy clients (notably macOS Finder) that refuse to
+// write to a share without a successful LOCK round-trip. Locks are
+// recorded but not actually enforced against concurrent writers.
+func (h *webdavHandler) lock(w http.ResponseWriter, r *http.Request) {
+	token, err := randomHex(16)
+	if err != nil {
+		writeS3Error(w, http.StatusInternalServerError, "InternalError", err.Error())
+		return
+	}
+	lockToken := "urn:uuid:" + token
+
+	h.mu.Lock()
+	h.locks[r.URL.Path] = lockToken
+	h.mu.Unlock()
+
+	w.Header().Set("Lock-Token", "<"+lockToken+">")
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="utf-8"?>
+<D:prop xmlns:D="DAV:"><D:lockdiscovery><D:activelock>
+<D:locktype><D:write/></D:locktype>
+<D:lockscope><D:exclusive/></D:lockscope>
+<D:depth>0</D:depth>
+<D:timeout>Second-600</D:timeout>
+<D:locktoken><D:href>%s</D:href></D:locktoken>
+</D:activelock></D:lockdiscovery></D:prop>`, lockToken)
+}
+
+func (h *webdavHandler) unlock(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	delete(h.locks, r.URL.Path)
+	h.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+//
+//
+//
+
+type davMultistatus struct {
+	XMLName  xml.Name      `xml:"D:multistatus"`
+	DAVAttr  string        `xml:"xmlns:D,attr"`
+	Response []davResponse `xml:"D:response"`
+}
+
+type davResponse struct {
+	Href     string      `xml:"D:href"`
+	Propstat davPropstat `xml:"D:propstat"`
+}
+
+type davPropstat struct {
+	Prop   davProp `xml:"D:prop"`
+	Status string  `xml:"D:status"`
+}
+
+type davProp struct {
+	ResourceType  davResourceType `xml:"D:resourcetype"`
+	ContentLength int64           `xml:"D:getcontentlength,omitempty"`
+	LastModified  string          `xml:"D:getlastmodified,omitempty"`
+	ETag          string          `xml:"D:getetag,omitempty"`
+}
+
+type davResourceType struct {
+	Collection *struct{} `xml:"D:collection,omitempty"`
+}
+
+func collectionResponse(href string, mod time.Time) davResponse {
+	prop := davProp{ResourceType: davResourceType{Collection: &struct{}{}}}
+	if !mod.IsZero() {
+		prop.LastModified = mod.UTC().Format(http.TimeFormat)
+	}
+	return davResponse{Href: href, Propstat: davPropstat{Prop: prop, Status: "HTTP/1.1 200 OK"}}
+}
+
+func fileResponse(href string, size int64, etag string, mod time.Time) davResponse {
+	return davResponse{
+		Href: href,
+		Propstat: davPropstat{
+			Prop: davProp{
+				ContentLength: size,
+				LastModified:  mod.UTC().Format(http.TimeFormat),
+				ETag:          fmt.Sprintf("\"%s\"", etag),
+			},
+			Status: "HTTP/1.1 200 OK",
+		},
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWebDAVPutGetRoundTrip(t *testing.T) {
+	h := NewWebDAVHandler(NewLocalFS(t.TempDir()), nil)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/bucket/dir/file.txt", strings.NewReader("hello webdav")))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("PUT: status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/bucket/dir/file.txt", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET: status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+	if rec.Body.String() != "hello webdav" {
+		t.Fatalf("GET body = %q, want %q", rec.Body.String(), "hello webdav")
+	}
+}
+
+func TestWebDAVPropfindListsCollection(t *testing.T) {
+	h := NewWebDAVHandler(NewLocalFS(t.TempDir()), nil)
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPut, "/bucket/dir/a.txt", strings.NewReader("a")))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPut, "/bucket/dir/b.txt", strings.NewReader("b")))
+
+	req := httptest.NewRequest("PROPFIND", "/bucket/dir/", nil)
+	req.Header.Set("Depth", "1")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusMultiStatus {
+		t.Fatalf("PROPFIND: status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+	body := rec.Body.String()
+	if !strings.Contains(body, "/bucket/dir/a.txt") || !strings.Contains(body, "/bucket/dir/b.txt") {
+		t.Fatalf("PROPFIND response missing expected children: %s", body)
+	}
+}
+
+func TestWebDAVMkcolAndDelete(t *testing.T) {
+	h := NewWebDAVHandler(NewLocalFS(t.TempDir()), nil)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("MKCOL", "/bucket/newdir/", nil))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("MKCOL: status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPut, "/bucket/newdir/f.txt", strings.NewReader("x")))
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/bucket/newdir/f.txt", nil))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("DELETE: status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/bucket/newdir/f.txt", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("GET after DELETE: status = %d, want 404", rec.Code)
+	}
+}
+
+// TestWebDAVRequiresAuth is a regression test: the WebDAV endpoint used to
+// be served with no auth at all, even when the operator locked down the S3
+// endpoint with --access-key/--secret-key.
+func TestWebDAVRequiresAuth(t *testing.T) {
+	creds := StaticCredentials{{AccessKeyID: testAccessKeyID, SecretAccessKey: testSecretAccessKey}}
+	h := NewWebDAVHandler(NewLocalFS(t.TempDir()), creds)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/bucket/file.txt", strings.NewReader("x")))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("unauthenticated PUT: status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+}
+
+// TestWebDAVCopyRequiresSourceReadAndDestinationWrite is a regression test:
+// WebDAV COPY/MOVE names its source in the URL path and its destination in
+// the Destination header, the reverse of the S3 x-amz-copy-source case, so
+// a credential scoped to write-only on an unrelated bucket used to be able
+// to COPY any object it could merely write past (the source's read
+// capability was never checked, and the destination bucket's write
+// capability was never checked either).
+func TestWebDAVCopyRequiresSourceReadAndDestinationWrite(t *testing.T) {
+	store := NewLocalFS(t.TempDir())
+	store.PutObject("private", "secret.txt", strings.NewReader("top secret"), 10)
+
+	creds := StaticCredentials{{
+		AccessKeyID:     testAccessKeyID,
+		SecretAccessKey: testSecretAccessKey,
+		Capabilities:    []Capability{{Bucket: "public", Write: true}},
+	}}
+	h := NewWebDAVHandler(store, creds)
+
+	req := signedRequest(t, "COPY", "http://example.com/private/secret.txt", time.Now())
+	req.Header.Set("Destination", "/public/exfiltrated.txt")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("COPY with no read capability on the source: status = %d, want %d, body = %s", rec.Code, http.StatusForbidden, rec.Body.String())
+	}
+
+	creds = StaticCredentials{{
+		AccessKeyID:     testAccessKeyID,
+		SecretAccessKey: testSecretAccessKey,
+		Capabilities: []Capability{
+			{Bucket: "private", Read: true},
+			{Bucket: "public", Write: true},
+		},
+	}}
+	h = NewWebDAVHandler(store, creds)
+
+	req = signedRequest(t, "COPY", "http://example.com/private/secret.txt", time.Now())
+	req.Header.Set("Destination", "/public/exfiltrated.txt")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("COPY with matching capabilities: status = %d, want %d, body = %s", rec.Code, http.StatusCreated, rec.Body.String())
+	}
+}